@@ -0,0 +1,218 @@
+package marketo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ActivityNewLead is the activity type recorded when a Lead is created
+	ActivityNewLead = 12
+	// ActivityChangeDataValue is the activity type recorded when a Lead
+	// field is changed
+	ActivityChangeDataValue = 13
+	// MaximumActivityTypeIDs is the largest number of activity types which
+	// may be requested at once
+	MaximumActivityTypeIDs = 10
+)
+
+const (
+	getPagingToken  = "get paging token"
+	getDeletedLeads = "get deleted leads"
+	getActivities   = "get activities"
+)
+
+// DeletedLead is the activity recorded when a Lead is deleted
+type DeletedLead struct {
+	ID                    int       `json:"id"`
+	MarketoGUID           string    `json:"marketoGUID"`
+	LeadID                int       `json:"leadId"`
+	ActivityDate          time.Time `json:"activityDate"`
+	ActivityTypeID        int       `json:"activityTypeId"`
+	PrimaryAttributeValue string    `json:"primaryAttributeValue"`
+}
+
+// ActivityAttribute is a single named attribute of an activity
+type ActivityAttribute struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// ActivityRecord is a single activity recorded for a Lead
+type ActivityRecord struct {
+	ID                      int                 `json:"id"`
+	MarketoGUID             string              `json:"marketoGUID"`
+	LeadID                  int                 `json:"leadId"`
+	ActivityDate            time.Time           `json:"activityDate"`
+	ActivityTypeID          int                 `json:"activityTypeId"`
+	PrimaryAttributeValueID int                 `json:"primaryAttributeValueId"`
+	PrimaryAttributeValue   string              `json:"primaryAttributeValue"`
+	Attributes              []ActivityAttribute `json:"attributes"`
+}
+
+// Attribute returns the value of the named attribute and whether it is
+// present.
+func (r ActivityRecord) Attribute(name string) (interface{}, bool) {
+	for _, a := range r.Attributes {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// DataValueChange describes a change to a single Lead field
+type DataValueChange struct {
+	// AttributeName is the name of the changed field, as recorded by
+	// Marketo in the activity's primary attribute
+	AttributeName string
+	OldValue      interface{}
+	NewValue      interface{}
+	Reason        string
+	Source        string
+}
+
+// DataValueChange returns the field change recorded by a Change Data Value
+// activity; ok is false if the activity is of another type.
+func (r ActivityRecord) DataValueChange() (change *DataValueChange, ok bool) {
+	if r.ActivityTypeID != ActivityChangeDataValue {
+		return nil, false
+	}
+
+	change = &DataValueChange{AttributeName: r.PrimaryAttributeValue}
+	change.OldValue, _ = r.Attribute("Old Value")
+	change.NewValue, _ = r.Attribute("New Value")
+	if reason, ok := r.Attribute("Reason"); ok {
+		change.Reason, _ = reason.(string)
+	}
+	if source, ok := r.Attribute("Source"); ok {
+		change.Source, _ = source.(string)
+	}
+	return change, true
+}
+
+// ActivitiesAPI provides access to the Marketo activities API
+type ActivitiesAPI struct {
+	*Client
+}
+
+// NewActivitiesAPI returns a new instance of the activities API, configured
+// with the provided Client.
+func NewActivitiesAPI(c *Client) *ActivitiesAPI {
+	return &ActivitiesAPI{c}
+}
+
+// PagingToken returns a paging token for activities recorded since the
+// provided time; the token is used to fetch the first page of activities.
+func (a *ActivitiesAPI) PagingToken(ctx context.Context, since time.Time) (string, error) {
+	path := a.restURL("activities", "pagingtoken.json") + "?" + url.Values{
+		"sinceDatetime": {since.Format(time.RFC3339)},
+	}.Encode()
+	response, err := a.get(ctx, getPagingToken, path)
+	if err != nil {
+		return "", err
+	}
+	if response.NextPageToken == "" {
+		return "", errors.New("no paging token returned")
+	}
+	return response.NextPageToken, nil
+}
+
+// DeletedLeads returns a page of deleted Lead activities, starting at the
+// paging token, along with the token for the next page. Marketo always
+// returns a next page token: once an empty page is returned the caller has
+// caught up, and may retain the token to fetch later deletions. If the token
+// has expired the returned error matches ErrStalePagingToken.
+func (a *ActivitiesAPI) DeletedLeads(ctx context.Context, token string) ([]DeletedLead, string, error) {
+	path := a.restURL("activities", "deletedleads.json") + "?" + url.Values{
+		"nextPageToken": {token},
+	}.Encode()
+	response, err := a.get(ctx, getDeletedLeads, path)
+	if err != nil {
+		return nil, "", pagingTokenError(err)
+	}
+
+	deleted := []DeletedLead{}
+	if len(response.Result) > 0 {
+		err = json.Unmarshal(response.Result, &deleted)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return deleted, response.NextPageToken, nil
+}
+
+// Activities returns a page of activities of up to MaximumActivityTypeIDs
+// types, starting at the paging token, along with the token for the next
+// page. If the token has expired the returned error matches
+// ErrStalePagingToken.
+func (a *ActivitiesAPI) Activities(ctx context.Context, token string, typeIDs ...int) ([]ActivityRecord, string, error) {
+	activities, response, err := a.leadActivities(ctx, token, typeIDs...)
+	if err != nil {
+		return nil, "", err
+	}
+	return activities, response.NextPageToken, nil
+}
+
+// leadActivities returns a page of activities of the provided types,
+// starting at the paging token.
+func (a *ActivitiesAPI) leadActivities(ctx context.Context, token string, typeIDs ...int) ([]ActivityRecord, *Response, error) {
+	if len(typeIDs) > MaximumActivityTypeIDs {
+		return nil, nil, errors.New("too many activity types")
+	}
+	ids := make([]string, len(typeIDs))
+	for i, id := range typeIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	path := a.restURL("activities.json") + "?" + url.Values{
+		"nextPageToken":   {token},
+		"activityTypeIds": {strings.Join(ids, ",")},
+	}.Encode()
+	response, err := a.get(ctx, getActivities, path)
+	if err != nil {
+		return nil, nil, pagingTokenError(err)
+	}
+
+	activities := []ActivityRecord{}
+	if len(response.Result) > 0 {
+		err = json.Unmarshal(response.Result, &activities)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return activities, response, nil
+}
+
+// get makes a GET request to path, returning the decoded response
+func (a *ActivitiesAPI) get(ctx context.Context, operation, path string) (*Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.Client.doRequest(operation, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(operation, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, responseError(operation, resp.StatusCode, response)
+	}
+	return response, nil
+}
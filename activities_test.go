@@ -0,0 +1,126 @@
+package marketo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDeletedLeads(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/activities/pagingtoken.json").
+		MatchParam("sinceDatetime", "2021-02-01T00:00:00Z").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"nextPageToken":"GIYDAOBNGEYS2MBWKQYDAORQGA5DAMBOGAYDAKZQGAYDALBQ"}`)
+	gock.New(testHost).
+		Get("/rest/v1/activities/deletedleads.json").
+		MatchParam("nextPageToken", "GIYDAOBNGEYS2MBWKQYDAORQGA5DAMBOGAYDAKZQGAYDALBQ").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"nextPageToken":"WQV2VQVPPCKHC6AQYVK7JDSA3I","moreResult":false,"result":[{"id":23,"marketoGUID":"23","leadId":1000049,"activityDate":"2021-02-02T09:30:00Z","activityTypeId":37,"primaryAttributeValue":"Nathan Yergler","attributes":[]}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewActivitiesAPI(client)
+
+	token, err := api.PagingToken(context.Background(), time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	deleted, next, err := api.DeletedLeads(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "WQV2VQVPPCKHC6AQYVK7JDSA3I", next)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, 1000049, deleted[0].LeadID)
+	assert.Equal(t, time.Date(2021, 2, 2, 9, 30, 0, 0, time.UTC), deleted[0].ActivityDate)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDeletedLeads_stalePagingToken(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/activities/deletedleads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":false,"errors":[{"code":"1003","message":"Invalid nextPageToken"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, _, err = NewActivitiesAPI(client).DeletedLeads(context.Background(), "expired")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrStalePagingToken))
+	var mErr Error
+	require.True(t, errors.As(err, &mErr))
+	assert.Equal(t, getDeletedLeads, mErr.Operation)
+	assert.True(t, gock.IsDone())
+}
+
+func TestActivities_dataValueChange(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/activities.json").
+		MatchParam("nextPageToken", "GIYDAOBNGEYS2MBWKQYDAORQGA5DAMBOGAYDAKZQGAYDALBQ").
+		MatchParam("activityTypeIds", "12,13").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"nextPageToken":"WQV2VQVPPCKHC6AQYVK7JDSA3I","moreResult":false,"result":[` +
+			`{"id":1,"marketoGUID":"1","leadId":1000049,"activityDate":"2021-02-02T09:30:00Z","activityTypeId":12,"primaryAttributeValueId":1000049,"attributes":[{"name":"Source Type","value":"Web service API"}]},` +
+			`{"id":2,"marketoGUID":"2","leadId":1000049,"activityDate":"2021-02-02T09:31:00Z","activityTypeId":13,"primaryAttributeValueId":31,"primaryAttributeValue":"Last Name","attributes":[{"name":"New Value","value":"Yergler"},{"name":"Old Value","value":null},{"name":"Reason","value":"Web service API"},{"name":"Source","value":"Web service API"}]}` +
+			`]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	activities, next, err := NewActivitiesAPI(client).Activities(
+		context.Background(),
+		"GIYDAOBNGEYS2MBWKQYDAORQGA5DAMBOGAYDAKZQGAYDALBQ",
+		ActivityNewLead, ActivityChangeDataValue,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "WQV2VQVPPCKHC6AQYVK7JDSA3I", next)
+	require.Len(t, activities, 2)
+
+	_, ok := activities[0].DataValueChange()
+	assert.False(t, ok)
+
+	change, ok := activities[1].DataValueChange()
+	require.True(t, ok)
+	assert.Equal(t, &DataValueChange{
+		AttributeName: "Last Name",
+		NewValue:      "Yergler",
+		Reason:        "Web service API",
+		Source:        "Web service API",
+	}, change)
+	assert.True(t, gock.IsDone())
+}
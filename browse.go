@@ -0,0 +1,97 @@
+package marketo
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// DefaultBrowsePageSize is the number of assets Marketo returns per page
+	// when maxReturn is not specified.
+	DefaultBrowsePageSize = 20
+	// MaximumBrowsePageSize is the largest maxReturn accepted by the Marketo
+	// asset APIs.
+	MaximumBrowsePageSize = 200
+)
+
+// BrowseFunc fetches up to maxReturn records starting at offset from an asset
+// API which uses offset paging.
+type BrowseFunc func(ctx context.Context, offset, maxReturn int) ([]interface{}, error)
+
+// Iterator steps through the records returned by an offset paged asset API,
+// fetching additional pages as needed.
+type Iterator struct {
+	ctx      context.Context
+	fetch    BrowseFunc
+	pageSize int
+
+	offset int
+	page   []interface{}
+	value  interface{}
+	done   bool
+	err    error
+}
+
+// BrowseAll returns an Iterator over all of the records returned by fetch,
+// requesting pageSize records at a time. A pageSize of 0 uses
+// DefaultBrowsePageSize. Iteration stops once fetch returns fewer than
+// pageSize records.
+func BrowseAll(ctx context.Context, fetch BrowseFunc, pageSize int) (*Iterator, error) {
+	if pageSize == 0 {
+		pageSize = DefaultBrowsePageSize
+	}
+	if pageSize < 0 || pageSize > MaximumBrowsePageSize {
+		return nil, fmt.Errorf(
+			"invalid page size %d: must be between 1 and %d", pageSize, MaximumBrowsePageSize,
+		)
+	}
+
+	return &Iterator{
+		ctx:      ctx,
+		fetch:    fetch,
+		pageSize: pageSize,
+	}, nil
+}
+
+// Next advances the Iterator to the next record, returning false when there
+// are no more records or an error has occurred. The records of the pages
+// fetched before an error are returned before Next returns false.
+func (i *Iterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+	if len(i.page) == 0 {
+		if i.done {
+			return false
+		}
+		if err := i.ctx.Err(); err != nil {
+			i.err = err
+			return false
+		}
+
+		page, err := i.fetch(i.ctx, i.offset, i.pageSize)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		i.offset += len(page)
+		i.page = page
+		i.done = len(page) < i.pageSize
+		if len(page) == 0 {
+			return false
+		}
+	}
+
+	i.value, i.page = i.page[0], i.page[1:]
+	return true
+}
+
+// Value returns the current record
+func (i *Iterator) Value() interface{} {
+	return i.value
+}
+
+// Err returns the error, if any, which stopped iteration
+func (i *Iterator) Err() error {
+	return i.err
+}
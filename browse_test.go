@@ -0,0 +1,60 @@
+package marketo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowseAll(t *testing.T) {
+	records := []interface{}{"a", "b", "c", "d", "e"}
+	offsets := []int{}
+	fetch := func(ctx context.Context, offset, maxReturn int) ([]interface{}, error) {
+		offsets = append(offsets, offset)
+		end := offset + maxReturn
+		if end > len(records) {
+			end = len(records)
+		}
+		return records[offset:end], nil
+	}
+
+	it, err := BrowseAll(context.Background(), fetch, 2)
+	require.NoError(t, err)
+
+	values := []interface{}{}
+	for it.Next() {
+		values = append(values, it.Value())
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, records, values)
+	// the short third page ends iteration without another request
+	assert.Equal(t, []int{0, 2, 4}, offsets)
+}
+
+func TestBrowseAllError(t *testing.T) {
+	failure := errors.New("boom")
+	fetch := func(ctx context.Context, offset, maxReturn int) ([]interface{}, error) {
+		if offset > 0 {
+			return nil, failure
+		}
+		return []interface{}{1, 2}, nil
+	}
+
+	it, err := BrowseAll(context.Background(), fetch, 2)
+	require.NoError(t, err)
+
+	count := 0
+	for it.Next() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+	assert.Equal(t, failure, it.Err())
+}
+
+func TestBrowseAllPageSize(t *testing.T) {
+	_, err := BrowseAll(context.Background(), nil, MaximumBrowsePageSize+1)
+	assert.Error(t, err)
+}
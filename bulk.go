@@ -8,16 +8,64 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ImportObject contains the bulk API paths used to import a type of Marketo
+// object
 type ImportObject struct {
 	create   string
 	status   string
 	failures string
+	warnings string
+	// params contains additional query parameters sent when creating an
+	// import
+	params url.Values
+}
+
+// NewImportObject returns an ImportObject for the provided paths, which are
+// relative to /bulk/v1/ and omit the .json extension. The status and failures
+// paths are format strings which receive the batch ID, ie "leads/batch/%d".
+// The warnings path is the failures path ending in warnings in place of
+// failures, as it is for each of Marketo's import APIs.
+func NewImportObject(create, status, failures string) ImportObject {
+	return ImportObject{
+		create:   create,
+		status:   status,
+		failures: failures,
+		warnings: strings.TrimSuffix(failures, "failures") + "warnings",
+	}
+}
+
+// CreatePath returns the path used to create an import
+func (o ImportObject) CreatePath() string {
+	return o.create
+}
+
+// StatusPath returns the format string of the path used to get the status of
+// an import
+func (o ImportObject) StatusPath() string {
+	return o.status
+}
+
+// FailuresPath returns the format string of the path used to get the failures
+// of an import
+func (o ImportObject) FailuresPath() string {
+	return o.failures
+}
+
+// WarningsPath returns the format string of the path used to get the
+// warnings of an import
+func (o ImportObject) WarningsPath() string {
+	return o.warnings
 }
 
 var (
@@ -25,12 +73,32 @@ var (
 		create:   "leads",
 		status:   "leads/batch/%d",
 		failures: "leads/batch/%d/failures",
+		warnings: "leads/batch/%d/warnings",
 	}
 	importObjects = map[string]ImportObject{
 		"lead": Leads,
 	}
 )
 
+// ProgramMembers returns the ImportObject used to import the members of the
+// program with programID; each member is added with the programMemberStatus,
+// which is required and must be one of the statuses of the program's channel,
+// ie "Registered". Program members are not imported with
+// ImportObjectForAPIName, whose paths are those of custom objects.
+//
+// The program is identified by its path rather than a programId parameter.
+// No acquiredBy option is provided, as the program member import endpoint
+// accepts only the format and programMemberStatus parameters.
+func ProgramMembers(programID int, programMemberStatus string) ImportObject {
+	obj := NewImportObject(
+		fmt.Sprintf("program/%d/members/import", programID),
+		"program/members/import/%d",
+		"program/members/import/%d/failures",
+	)
+	obj.params = url.Values{"programMemberStatus": {programMemberStatus}}
+	return obj
+}
+
 // ImportObjectForAPIName returns the ImportObject given the API name
 // of a Marketo object
 func ImportObjectForAPIName(apiName string) ImportObject {
@@ -38,13 +106,27 @@ func ImportObjectForAPIName(apiName string) ImportObject {
 		return obj
 	}
 
-	return ImportObject{
-		create:   fmt.Sprintf("customobjects/%s/import", apiName),
-		status:   fmt.Sprintf("customobjects/%s/import/%%d/status", apiName),
-		failures: fmt.Sprintf("customobjects/%s/import/%%d/failures", apiName),
-	}
+	return NewImportObject(
+		fmt.Sprintf("customobjects/%s/import", apiName),
+		fmt.Sprintf("customobjects/%s/import/%%d/status", apiName),
+		fmt.Sprintf("customobjects/%s/import/%%d/failures", apiName),
+	)
 }
 
+const (
+	// MaximumImportFileSize is the largest file, in bytes, accepted by
+	// Marketo's bulk import API.
+	MaximumImportFileSize = 10 * 1024 * 1024
+)
+
+var (
+	// importBackoff is the initial wait before retrying an import when
+	// too many imports are queued; it doubles with each attempt up to
+	// maximumImportBackoff.
+	importBackoff        = 5 * time.Second
+	maximumImportBackoff = time.Minute
+)
+
 const (
 	BatchComplete  = "Complete"
 	BatchQueued    = "Queued"
@@ -56,6 +138,7 @@ const (
 	createImport      = "create bulk import"
 	getImport         = "get import status"
 	getImportFailures = "get import failures"
+	getImportWarnings = "get import warnings"
 )
 
 // BatchResult contains the details of a batch, returned by the Create
@@ -85,14 +168,226 @@ func NewImportAPI(c *Client) *ImportAPI {
 	return &ImportAPI{c}
 }
 
+// ImportSchema describes the columns accepted when importing an object
+type ImportSchema struct {
+	// Fields contains the names of the fields which may be imported
+	Fields []string
+	// DedupeFields contains the fields which must be present to match
+	// imported records to existing ones
+	DedupeFields []string
+}
+
+// LeadImportSchema returns the ImportSchema for Leads described by fields;
+// Leads are deduplicated by email.
+func LeadImportSchema(fields []LeadAttribute2) ImportSchema {
+	schema := ImportSchema{
+		Fields:       make([]string, len(fields)),
+		DedupeFields: []string{"email"},
+	}
+	for i, f := range fields {
+		schema.Fields[i] = f.Name
+	}
+	return schema
+}
+
+// ImportSchema returns the ImportSchema for the custom object
+func (m CustomObjectMetadata) ImportSchema() ImportSchema {
+	schema := ImportSchema{
+		Fields:       make([]string, len(m.Fields)),
+		DedupeFields: append([]string{}, m.DedupeFields...),
+	}
+	for i, f := range m.Fields {
+		schema.Fields[i] = f.Name
+	}
+	return schema
+}
+
+// ValidateImport returns an error if the header of an import file is missing
+// any of the schema's dedupe fields or contains a field not in the schema.
+func ValidateImport(header []string, schema ImportSchema) error {
+	known := make(map[string]bool, len(schema.Fields))
+	for _, f := range schema.Fields {
+		known[f] = true
+	}
+	present := make(map[string]bool, len(header))
+	for _, f := range header {
+		if !known[f] {
+			return fmt.Errorf("invalid import field %q", f)
+		}
+		present[f] = true
+	}
+	for _, f := range schema.DedupeFields {
+		if !present[f] {
+			return fmt.Errorf("import is missing dedupe field %q", f)
+		}
+	}
+	return nil
+}
+
+// BuildImportCSV returns a CSV file suitable for passing to Create, with a
+// header row containing fields followed by a row for each record. Missing
+// and nil values are written as empty cells.
+func BuildImportCSV(records []map[string]interface{}, fields []string) (io.Reader, error) {
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write(fields); err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		row, err := csvRow(record, fields)
+		if err != nil {
+			return nil, fmt.Errorf("record %d, %w", i, err)
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// csvRow returns the values of fields in record
+func csvRow(record map[string]interface{}, fields []string) ([]string, error) {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		value, err := csvValue(record[field])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		row[i] = value
+	}
+	return row, nil
+}
+
+// csvValue returns the string representation of v for an import file
+func csvValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case json.Number:
+		return value.String(), nil
+	case time.Time:
+		return value.Format(time.RFC3339), nil
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+// ImportOption defines the signature of functional options for the Marketo
+// import API.
+type ImportOption func(*importOptions)
+
+type importOptions struct {
+	waitForSlot bool
+	waitTimeout time.Duration
+	fieldName   string
+	fileName    string
+	schema      *ImportSchema
+}
+
+const (
+	defaultImportFieldName = "file"
+	defaultImportFileName  = "import.csv"
+)
+
+// quoteEscaper escapes quoted strings in the Content-Disposition header, as
+// mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// WaitForSlot configures Create to wait, with backoff, and retry while
+// Marketo reports too many imports are queued. Create gives up after timeout;
+// a timeout of 0 waits until the context is done.
+func WaitForSlot(timeout time.Duration) ImportOption {
+	return func(o *importOptions) {
+		o.waitForSlot = true
+		o.waitTimeout = timeout
+	}
+}
+
+// ValidateAgainst configures Create to check the header of the import file
+// with ValidateImport before uploading it.
+func ValidateAgainst(schema ImportSchema) ImportOption {
+	return func(o *importOptions) {
+		o.schema = &schema
+	}
+}
+
+// WithFieldName sets the name of the multipart form field containing the
+// import file; the default is "file".
+func WithFieldName(name string) ImportOption {
+	return func(o *importOptions) {
+		o.fieldName = name
+	}
+}
+
+// WithFileName sets the filename sent with the import file; the default is
+// "import.csv".
+func WithFileName(name string) ImportOption {
+	return func(o *importOptions) {
+		o.fileName = name
+	}
+}
+
 // Create uploads a new file for importing, returning the new
-// asynchronous import
-func (i *ImportAPI) Create(ctx context.Context, obj ImportObject, file io.Reader) ([]BatchResult, error) {
-	buffer := &strings.Builder{}
+// asynchronous import. The file is read into memory before the request is
+// made, and the token is refreshed first if it has expired, so the upload can
+// be retried if Marketo rejects the token.
+func (i *ImportAPI) Create(ctx context.Context, obj ImportObject, file io.Reader, opts ...ImportOption) ([]BatchResult, error) {
+	options := &importOptions{
+		fieldName: defaultImportFieldName,
+		fileName:  defaultImportFileName,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.schema != nil {
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		header, err := csv.NewReader(bytes.NewReader(data)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read import header: %w", err)
+		}
+		if err := ValidateImport(header, *options.schema); err != nil {
+			return nil, err
+		}
+		file = bytes.NewReader(data)
+	}
+
+	buffer := &bytes.Buffer{}
 	mpWriter := multipart.NewWriter(buffer)
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition",
-		fmt.Sprintf(`form-data; name="file"; filename="%s"`, "import.csv"))
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(options.fieldName),
+			quoteEscaper.Replace(options.fileName),
+		))
 
 	fileWriter, err := mpWriter.CreatePart(h)
 	if err != nil {
@@ -102,18 +397,54 @@ func (i *ImportAPI) Create(ctx context.Context, obj ImportObject, file io.Reader
 	if err != nil {
 		return nil, err
 	}
-
 	mpWriter.Close()
-	request, err := http.NewRequest(http.MethodPost,
-		i.url("bulk", "v1", fmt.Sprintf("%s.json?format=csv", obj.create)),
-		bytes.NewBufferString(buffer.String()),
+
+	if !options.waitForSlot {
+		return i.create(ctx, obj, buffer.Bytes(), mpWriter.FormDataContentType())
+	}
+
+	var deadline <-chan time.Time
+	if options.waitTimeout > 0 {
+		timer := time.NewTimer(options.waitTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	wait := importBackoff
+	for {
+		results, err := i.create(ctx, obj, buffer.Bytes(), mpWriter.FormDataContentType())
+		if !errors.Is(err, ErrTooManyImports) {
+			return results, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-deadline:
+			return nil, err
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maximumImportBackoff {
+			wait = maximumImportBackoff
+		}
+	}
+}
+
+// create makes a single request to create an import with the multipart body
+func (i *ImportAPI) create(ctx context.Context, obj ImportObject, body []byte, contentType string) ([]BatchResult, error) {
+	params := url.Values{"format": {"csv"}}
+	for k, v := range obj.params {
+		params[k] = v
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		i.url("bulk", "v1", fmt.Sprintf("%s.json?%s", obj.create, params.Encode())),
+		bytes.NewReader(body),
 	)
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Add("Content-Type", mpWriter.FormDataContentType())
+	request.Header.Add("Content-Type", contentType)
 
-	resp, err := i.Client.doRequest(request)
+	resp, err := i.Client.doRequest(createImport, request)
 	if err != nil {
 		return nil, err
 	}
@@ -129,7 +460,7 @@ func (i *ImportAPI) Create(ctx context.Context, obj ImportObject, file io.Reader
 		return nil, err
 	}
 	if len(response.Errors) > 0 {
-		return nil, ErrorForReasons(resp.StatusCode, response.Errors...)
+		return nil, responseError(createImport, resp.StatusCode, response)
 	}
 
 	results := []BatchResult{}
@@ -141,10 +472,75 @@ func (i *ImportAPI) Create(ctx context.Context, obj ImportObject, file io.Reader
 	return results, nil
 }
 
+// CreateChunked imports records, splitting them into as many import files as
+// needed to keep each under MaximumImportFileSize, and returns a BatchResult
+// for each file. Unless overridden with WaitForSlot, CreateChunked waits for a
+// slot until ctx is done when Marketo reports too many imports are queued. If
+// an import fails, the results for the files already imported are returned
+// along with the error.
+func (i *ImportAPI) CreateChunked(ctx context.Context, obj ImportObject, records []map[string]interface{}, fields []string, opts ...ImportOption) ([]BatchResult, error) {
+	opts = append([]ImportOption{WaitForSlot(0)}, opts...)
+
+	header := &bytes.Buffer{}
+	writer := csv.NewWriter(header)
+	writer.Write(fields)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	results := []BatchResult{}
+	chunk := bytes.NewBuffer(append([]byte{}, header.Bytes()...))
+	rows := 0
+	flush := func() error {
+		batches, err := i.Create(ctx, obj, bytes.NewReader(chunk.Bytes()), opts...)
+		if err != nil {
+			return err
+		}
+		results = append(results, batches...)
+		chunk = bytes.NewBuffer(append([]byte{}, header.Bytes()...))
+		rows = 0
+		return nil
+	}
+
+	line := &bytes.Buffer{}
+	writer = csv.NewWriter(line)
+	for n, record := range records {
+		row, err := csvRow(record, fields)
+		if err != nil {
+			return results, fmt.Errorf("record %d, %w", n, err)
+		}
+		line.Reset()
+		writer.Write(row)
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return results, err
+		}
+		if header.Len()+line.Len() > MaximumImportFileSize {
+			return results, fmt.Errorf("record %d exceeds the maximum import file size", n)
+		}
+
+		if rows > 0 && chunk.Len()+line.Len() > MaximumImportFileSize {
+			if err := flush(); err != nil {
+				return results, err
+			}
+		}
+		chunk.Write(line.Bytes())
+		rows++
+	}
+
+	if rows > 0 {
+		if err := flush(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
 // Get retrieves an existing import by its batch ID
 func (i *ImportAPI) Get(ctx context.Context, obj ImportObject, id int) (*BatchResult, error) {
-	request, err := http.NewRequest(
-		http.MethodGet, i.url("bulk", "v1", fmt.Sprintf("%s.json",
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, i.url("bulk", "v1", fmt.Sprintf("%s.json",
 			fmt.Sprintf(obj.status, id),
 		)), nil,
 	)
@@ -152,7 +548,7 @@ func (i *ImportAPI) Get(ctx context.Context, obj ImportObject, id int) (*BatchRe
 		return nil, err
 	}
 
-	resp, err := i.Client.doRequest(request)
+	resp, err := i.Client.doRequest(getImport, request)
 	if err != nil {
 		return nil, err
 	}
@@ -168,7 +564,7 @@ func (i *ImportAPI) Get(ctx context.Context, obj ImportObject, id int) (*BatchRe
 		return nil, err
 	}
 	if len(response.Errors) > 0 {
-		return nil, ErrorForReasons(resp.StatusCode, response.Errors...)
+		return nil, responseError(getImport, resp.StatusCode, response)
 	}
 
 	result := []BatchResult{}
@@ -198,46 +594,78 @@ type LeadImportFailure struct {
 
 // Failures returns the list of failed recrods for an import
 func (i *ImportAPI) Failures(ctx context.Context, obj ImportObject, id int) ([]LeadImportFailure, error) {
-	request, err := http.NewRequest(
-		http.MethodGet, i.url("bulk", "v1", fmt.Sprintf("%s.json",
-			fmt.Sprintf(obj.failures, id),
-		)), nil,
+	failures := []LeadImportFailure{}
+	err := i.importReport(ctx, getImportFailures, fmt.Sprintf(obj.failures, id),
+		func(reason string, fields map[string]interface{}) {
+			failures = append(failures, LeadImportFailure{Reason: reason, Fields: fields})
+		},
 	)
 	if err != nil {
 		return nil, err
 	}
+	return failures, nil
+}
 
-	resp, err := i.Client.doRequest(request)
+// LeadImportWarning contains a single record imported with a warning, such as
+// a value which was truncated; see ImportTruncations.
+type LeadImportWarning struct {
+	Warning string
+	Fields  map[string]interface{}
+}
+
+// Warnings returns the list of records imported with warnings
+func (i *ImportAPI) Warnings(ctx context.Context, obj ImportObject, id int) ([]LeadImportWarning, error) {
+	warnings := []LeadImportWarning{}
+	err := i.importReport(ctx, getImportWarnings, fmt.Sprintf(obj.warnings, id),
+		func(warning string, fields map[string]interface{}) {
+			warnings = append(warnings, LeadImportWarning{Warning: warning, Fields: fields})
+		},
+	)
 	if err != nil {
 		return nil, err
 	}
+	return warnings, nil
+}
+
+// importReport reads the failures or warnings file of an import at path,
+// calling record with the final column, which describes the failure or
+// warning, and the remaining fields of each record. A missing file contains
+// no records.
+func (i *ImportAPI) importReport(ctx context.Context, operation, path string, record func(string, map[string]interface{})) error {
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, i.url("bulk", "v1", fmt.Sprintf("%s.json", path)), nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := i.Client.doRequest(operation, request)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
 		// no errors
-		return nil, nil
+		return nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, handleError(getImportFailures, resp)
+		return handleError(operation, resp)
 	}
 
 	reader := csv.NewReader(resp.Body)
 	header, err := reader.Read()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	failures := []LeadImportFailure{}
-	record, err := reader.Read()
+	row, err := reader.Read()
 	for err == nil {
-		failure := LeadImportFailure{
-			Reason: record[len(header)-1],
-			Fields: map[string]interface{}{},
-		}
+		fields := map[string]interface{}{}
 		for i := 0; i < len(header)-1; i++ {
-			failure.Fields[header[i]] = record[i]
+			fields[header[i]] = row[i]
 		}
-		failures = append(failures, failure)
-		record, err = reader.Read()
+		record(row[len(header)-1], fields)
+		row, err = reader.Read()
 	}
-	return failures, nil
+	return nil
 }
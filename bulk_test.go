@@ -0,0 +1,296 @@
+package marketo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestBuildImportCSV(t *testing.T) {
+	file, err := BuildImportCSV(
+		[]map[string]interface{}{
+			{
+				"email":     "nathan@polytomic.com",
+				"company":   "Polytomic, Inc.",
+				"score":     12,
+				"revenue":   1500000.5,
+				"recordId":  json.Number("1234567890123456789"),
+				"active":    true,
+				"createdAt": time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+			},
+			{
+				"email":   "ghalib@polytomic.com",
+				"company": nil,
+			},
+		},
+		[]string{"email", "company", "score", "revenue", "recordId", "active", "createdAt"},
+	)
+	require.NoError(t, err)
+
+	body, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"email,company,score,revenue,recordId,active,createdAt\n"+
+			"nathan@polytomic.com,\"Polytomic, Inc.\",12,1500000.5,1234567890123456789,true,2021-03-04T05:06:07Z\n"+
+			"ghalib@polytomic.com,,,,,,\n",
+		string(body),
+	)
+
+	_, err = BuildImportCSV(
+		[]map[string]interface{}{{"tags": []string{"a"}}},
+		[]string{"tags"},
+	)
+	assert.EqualError(t, err, "record 0, field tags: unsupported value type []string")
+}
+
+func TestCreateChunked(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	sizes := []int{}
+	gock.New(testHost).
+		Post("/bulk/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			sizes = append(sizes, int(r.ContentLength))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"batchId":1,"status":"Queued"}]}`)
+	gock.New(testHost).
+		Post("/bulk/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":false,"errors":[{"code":"1016","message":"Too many imports"}]}`)
+	gock.New(testHost).
+		Post("/bulk/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"3","success":true,"result":[{"batchId":2,"status":"Queued"}]}`)
+
+	defer func(wait time.Duration) { importBackoff = wait }(importBackoff)
+	importBackoff = time.Millisecond
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	// 12,000 rows of 1KiB require two files
+	padding := strings.Repeat("x", 1024)
+	records := make([]map[string]interface{}, 12000)
+	for i := range records {
+		records[i] = map[string]interface{}{"email": fmt.Sprintf("lead%d@example.com", i), "notes": padding}
+	}
+
+	results, err := NewImportAPI(client).CreateChunked(
+		context.Background(), Leads, records, []string{"email", "notes"},
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].BatchID)
+	assert.Equal(t, 2, results[1].BatchID)
+	for _, size := range sizes {
+		assert.Less(t, size, MaximumImportFileSize+1024)
+	}
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreate_waitForSlot(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/leads.json").
+		Persist().
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":false,"errors":[{"code":"1016","message":"Too many imports"}]}`)
+
+	defer func(wait time.Duration) { importBackoff = wait }(importBackoff)
+	importBackoff = time.Millisecond
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	api := NewImportAPI(client)
+	_, err = api.Create(context.Background(), Leads, strings.NewReader("email\n"))
+	assert.True(t, errors.Is(err, ErrTooManyImports))
+
+	start := time.Now()
+	_, err = api.Create(
+		context.Background(), Leads, strings.NewReader("email\n"),
+		WaitForSlot(50*time.Millisecond),
+	)
+	assert.True(t, errors.Is(err, ErrTooManyImports))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+}
+
+func TestCreate_fileName(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseMultipartForm(1024))
+			require.Contains(t, r.MultipartForm.File, "upload")
+			assert.Equal(t, "leads-2021-02-01.csv", r.MultipartForm.File["upload"][0].Filename)
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"batchId":1,"status":"Queued"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = NewImportAPI(client).Create(
+		context.Background(), Leads, strings.NewReader("email\n"),
+		WithFieldName("upload"), WithFileName("leads-2021-02-01.csv"),
+	)
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestNewImportObject(t *testing.T) {
+	obj := NewImportObject("leads", "leads/batch/%d", "leads/batch/%d/failures")
+	assert.Equal(t, Leads, obj)
+	assert.Equal(t, "leads", obj.CreatePath())
+	assert.Equal(t, "leads/batch/%d", obj.StatusPath())
+	assert.Equal(t, "leads/batch/%d/failures", obj.FailuresPath())
+	assert.Equal(t, "leads/batch/%d/warnings", obj.WarningsPath())
+	assert.Equal(t,
+		"customobjects/testObject_c/import/%d/warnings",
+		ImportObjectForAPIName("testObject_c").WarningsPath(),
+	)
+
+	assert.Equal(t,
+		NewImportObject(
+			"customobjects/testObject_c/import",
+			"customobjects/testObject_c/import/%d/status",
+			"customobjects/testObject_c/import/%d/failures",
+		),
+		ImportObjectForAPIName("testObject_c"),
+	)
+}
+
+func TestValidateImport(t *testing.T) {
+	schema := CustomObjectMetadata{
+		Fields:       []ObjectField{{Name: "email"}, {Name: "firstName"}, {Name: "lastName"}},
+		DedupeFields: []string{"email"},
+	}.ImportSchema()
+
+	assert.NoError(t, ValidateImport([]string{"email", "firstName"}, schema))
+	assert.EqualError(t, ValidateImport([]string{"firstName"}, schema), `import is missing dedupe field "email"`)
+	assert.EqualError(t, ValidateImport([]string{"email", "nickname"}, schema), `invalid import field "nickname"`)
+
+	// validation fails before any request is made
+	_, err := (&ImportAPI{}).Create(
+		context.Background(), Leads, strings.NewReader("firstName\nNathan\n"),
+		ValidateAgainst(LeadImportSchema([]LeadAttribute2{{Name: "email"}, {Name: "firstName"}})),
+	)
+	assert.EqualError(t, err, `import is missing dedupe field "email"`)
+}
+
+func TestImportWarnings(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/bulk/v1/leads/batch/1012/warnings.json").
+		Reply(http.StatusOK).
+		BodyString("email,title,Import Warning\n" +
+			"tester@example.com,Vice President of Everything,Value for field 'title' truncated to maximum length 20\n" +
+			"other@example.com,CEO,Invalid picklist value\n")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	warnings, err := NewImportAPI(client).Warnings(context.Background(), Leads, 1012)
+	require.NoError(t, err)
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "Invalid picklist value", warnings[1].Warning)
+	assert.Equal(t, "other@example.com", warnings[1].Fields["email"])
+
+	assert.Equal(t, []TruncationWarning{{
+		Index:          0,
+		Field:          "title",
+		OriginalLength: 28,
+		MaxLength:      20,
+		Message:        "Value for field 'title' truncated to maximum length 20",
+	}}, ImportTruncations(warnings))
+	assert.True(t, gock.IsDone())
+}
+
+func TestImportProgramMembers(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/program/1001/members/import.json").
+		MatchParam("format", "csv").
+		MatchParam("programMemberStatus", "Registered").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"batchId":5,"importId":"5","status":"Queued"}]}`)
+	gock.New(testHost).
+		Get("/bulk/v1/program/members/import/5.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[{"batchId":5,"importId":"5","status":"Complete","numOfRowsFailed":0,"numOfRowsWithWarning":0}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	obj := ProgramMembers(1001, "Registered")
+	assert.Equal(t, "program/members/import/%d", obj.StatusPath())
+	assert.Equal(t, "program/members/import/%d/failures", obj.FailuresPath())
+	assert.Equal(t, "program/members/import/%d/warnings", obj.WarningsPath())
+
+	api := NewImportAPI(client)
+	results, err := api.Create(context.Background(), obj, strings.NewReader("email\ntester@example.com\n"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	result, err := api.Get(context.Background(), obj, results[0].BatchID)
+	require.NoError(t, err)
+	assert.Equal(t, BatchComplete, result.Status)
+	assert.True(t, gock.IsDone())
+}
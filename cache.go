@@ -0,0 +1,103 @@
+package marketo
+
+import (
+	"sync"
+	"time"
+)
+
+// describeCache holds describe results keyed by object kind, such as "lead"
+// or "customobject", and name. A Client's describeCache is shared by the APIs
+// created with it, so a description fetched by one is seen by the others.
+// Results are served from the cache for ttl after being stored; a ttl of 0
+// disables serving from the cache, although the most recent result is still
+// retained for client-side validation.
+type describeCache struct {
+	ttl time.Duration
+
+	lock    sync.Mutex
+	entries map[describeKey]describeEntry
+	stats   map[string]DescribeCacheStats
+}
+
+// DescribeCacheStats reports how many describe calls were served from the
+// cache (Hits) and how many were fetched from Marketo (Misses).
+type DescribeCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// describeKey identifies a cached description
+type describeKey struct {
+	kind string
+	name string
+}
+
+type describeEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func newDescribeCache(ttl time.Duration) *describeCache {
+	return &describeCache{
+		ttl:     ttl,
+		entries: map[describeKey]describeEntry{},
+		stats:   map[string]DescribeCacheStats{},
+	}
+}
+
+// get returns the value stored for key if it has not expired.
+func (d *describeCache) get(key describeKey) (interface{}, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := d.stats[key.kind]
+	defer func() { d.stats[key.kind] = stats }()
+
+	entry, ok := d.entries[key]
+	if !ok || d.ttl == 0 || time.Now().After(entry.expires) {
+		stats.Misses++
+		return nil, false
+	}
+	stats.Hits++
+	return entry.value, true
+}
+
+// last returns the most recent value stored for key, regardless of whether
+// it has expired.
+func (d *describeCache) last(key describeKey) (interface{}, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	entry, ok := d.entries[key]
+	return entry.value, ok
+}
+
+func (d *describeCache) set(key describeKey, value interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.entries[key] = describeEntry{
+		value:   value,
+		expires: time.Now().Add(d.ttl),
+	}
+}
+
+// describeStats returns the hits and misses recorded by get for kind
+func (d *describeCache) describeStats(kind string) DescribeCacheStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.stats[kind]
+}
+
+// clear discards the values stored for kind
+func (d *describeCache) clear(kind string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for key := range d.entries {
+		if key.kind == kind {
+			delete(d.entries, key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package marketo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// MaximumTriggerBatchSize is the largest number of leads which may be
+	// passed to a campaign in a single request.
+	MaximumTriggerBatchSize = 100
+
+	triggerCampaign = "trigger campaign"
+)
+
+// CampaignToken overrides the value of a My Token in the triggered campaign,
+// ie {Name: "{{my.message}}", Value: "Hello"}
+type CampaignToken struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type leadID struct {
+	ID int `json:"id"`
+}
+
+type triggerInput struct {
+	Leads  []leadID        `json:"leads"`
+	Tokens []CampaignToken `json:"tokens,omitempty"`
+}
+
+type triggerRequest struct {
+	Input triggerInput `json:"input"`
+}
+
+// CampaignsAPI provides access to the Marketo campaigns API
+type CampaignsAPI struct {
+	*Client
+}
+
+// NewCampaignsAPI returns a new instance of the campaigns API, configured with
+// the provided Client.
+func NewCampaignsAPI(c *Client) *CampaignsAPI {
+	return &CampaignsAPI{c}
+}
+
+// Trigger passes the leads to a trigger campaign with a "Campaign is
+// Requested" trigger, returning the ID of the triggered campaign. Marketo
+// responds with only the campaign's ID, ie [{"id":1234}], and not a result
+// for each lead: leads which do not qualify for the campaign's smart list are
+// silently not processed.
+func (c *CampaignsAPI) Trigger(ctx context.Context, campaignID int, leadIDs []int, tokens ...CampaignToken) (int, error) {
+	if len(leadIDs) < 1 {
+		return 0, errors.New("too few leads")
+	}
+	if len(leadIDs) > MaximumTriggerBatchSize {
+		return 0, errors.New("too many leads")
+	}
+
+	tr := triggerRequest{Input: triggerInput{
+		Leads:  make([]leadID, len(leadIDs)),
+		Tokens: tokens,
+	}}
+	for i, id := range leadIDs {
+		tr.Input.Leads[i] = leadID{ID: id}
+	}
+	body, err := json.Marshal(tr)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.restURL("campaigns", fmt.Sprintf("%d", campaignID), "trigger.json"),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Add("Content-Type", "application/json")
+
+	resp, err := c.Client.doRequest(triggerCampaign, request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, handleError(triggerCampaign, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return 0, err
+	}
+	if len(response.Errors) > 0 {
+		return 0, responseError(triggerCampaign, resp.StatusCode, response)
+	}
+
+	triggered := []struct {
+		ID int `json:"id"`
+	}{}
+	if len(response.Result) > 0 {
+		err = json.Unmarshal(response.Result, &triggered)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(triggered) == 0 {
+		return campaignID, nil
+	}
+	return triggered[0].ID, nil
+}
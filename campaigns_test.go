@@ -0,0 +1,46 @@
+package marketo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTriggerCampaign(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/campaigns/1234/trigger.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"input": map[string]interface{}{
+				"leads":  []map[string]interface{}{{"id": 1000048}, {"id": 1000049}},
+				"tokens": []map[string]interface{}{{"name": "{{my.message}}", "value": "Hello"}},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"id":1234}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	id, err := NewCampaignsAPI(client).Trigger(
+		context.Background(), 1234, []int{1000048, 1000049},
+		CampaignToken{Name: "{{my.message}}", Value: "Hello"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1234, id)
+	assert.True(t, gock.IsDone())
+}
@@ -2,12 +2,19 @@ package marketo
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
@@ -18,13 +25,127 @@ const (
 	DefaultTimeout = 60
 	identityBase   = "/identity"
 	identityPath   = "/oauth/token"
+	// DefaultRESTVersion is the REST API version used when none is
+	// configured
+	DefaultRESTVersion = "v1"
+	// DefaultMaxResponseBytes is the largest response body read into
+	// memory when no MaxResponseBytes is configured
+	DefaultMaxResponseBytes = 64 << 20
+	// DefaultGatewayRetries is the number of times a request is retried
+	// when the gateway in front of Marketo returns a 502, 503 or 504
+	DefaultGatewayRetries = 2
+
+	modulePath = "github.com/polytomic/go-marketo"
 )
 
+// DefaultUserAgent is the User-Agent sent when none is configured; it
+// includes the version of this module when it is available from the build
+// information.
+var DefaultUserAgent = defaultUserAgent()
+
+// gatewayBackoff is the initial wait before retrying a request rejected by
+// the gateway; it doubles with each attempt.
+var gatewayBackoff = 500 * time.Millisecond
+
+// ErrResponseTooLarge is matched, using errors.Is, by the error returned when
+// a response body is larger than the configured MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+func defaultUserAgent() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath && dep.Version != "" {
+				return "go-marketo/" + dep.Version
+			}
+		}
+	}
+	return "go-marketo"
+}
+
 // RecordResult holds Marketo record-level result
 type RecordResult struct {
-	ID      int      `json:"id"`
-	Status  string   `json:"status"`
-	Reasons []Reason `json:"reasons,omitempty"`
+	ID int `json:"id"`
+	// MarketoGUID identifies custom object records, which do not have an
+	// integer ID
+	MarketoGUID string   `json:"marketoGUID,omitempty"`
+	Status      string   `json:"status"`
+	Reasons     []Reason `json:"reasons,omitempty"`
+}
+
+const (
+	RecordCreated = "created"
+	RecordUpdated = "updated"
+	RecordDeleted = "deleted"
+	RecordSkipped = "skipped"
+	RecordFailed  = "failed"
+)
+
+// Failed returns true if Marketo did not process the record; the Reasons
+// explain why.
+func (r RecordResult) Failed() bool {
+	return r.Status == RecordSkipped || r.Status == RecordFailed
+}
+
+// Err returns an Error wrapping the Reasons a record failed, or nil if
+// Marketo processed it. The returned Error may be tested using errors.Is, ie
+// errors.Is(r.Err(), ErrPartitionAccessDenied); its StatusCode is zero, as
+// the failure of a record is not an HTTP error.
+func (r RecordResult) Err() error {
+	if !r.Failed() {
+		return nil
+	}
+	return ErrorForReasons(0, r.Reasons...)
+}
+
+// PartitionResults separates the records which Marketo processed from those
+// which it skipped or failed to process.
+func PartitionResults(results []RecordResult) (succeeded, failed []RecordResult) {
+	for _, r := range results {
+		if r.Failed() {
+			failed = append(failed, r)
+		} else {
+			succeeded = append(succeeded, r)
+		}
+	}
+	return succeeded, failed
+}
+
+// ResultSummary counts the records of a sync by their Status
+type ResultSummary struct {
+	Created int
+	Updated int
+	Deleted int
+	Skipped int
+	Failed  int
+	// Other counts records with any other Status, including none
+	Other int
+}
+
+// Total returns the number of records summarized
+func (s ResultSummary) Total() int {
+	return s.Created + s.Updated + s.Deleted + s.Skipped + s.Failed + s.Other
+}
+
+// SummarizeResults counts results by their Status
+func SummarizeResults(results []RecordResult) ResultSummary {
+	summary := ResultSummary{}
+	for _, r := range results {
+		switch r.Status {
+		case RecordCreated:
+			summary.Created++
+		case RecordUpdated:
+			summary.Updated++
+		case RecordDeleted:
+			summary.Deleted++
+		case RecordSkipped:
+			summary.Skipped++
+		case RecordFailed:
+			summary.Failed++
+		default:
+			summary.Other++
+		}
+	}
+	return summary
 }
 
 // Response is the common Marketo response which covers most of the Marketo response format
@@ -35,8 +156,8 @@ type Response struct {
 	MoreResult    bool            `json:"moreResult,omitempty"`
 	Errors        []Reason        `json:"errors,omitempty"`
 	Result        json.RawMessage `json:"result,omitempty"`
-	Warnings      []Reason        `json:"warning,omitempty"`
-	Raw          []byte
+	Warnings      []Reason        `json:"warnings,omitempty"`
+	Raw           []byte          `json:"-"`
 }
 
 // AuthToken holds data from Auth request
@@ -49,15 +170,25 @@ type AuthToken struct {
 
 // Client Marketo http Client
 type Client struct {
-	authClient       *http.Client
+	tokenSource      TokenSource
 	restClient       *http.Client
 	restRoundTripper *restRoundTripper
 	endpoint         string
-	identityEndpoint string
+	restVersion      string
 	authLock         sync.Mutex
 	auth             *AuthToken
 	tokenExpiresAt   time.Time
 	debug            bool
+	tracer           Tracer
+	onRequest        RequestHook
+	describe         *describeCache
+	validateFilters  bool
+	disableRefresh   bool
+	maxResponseBytes int64
+	gatewayRetries   int
+	retryWrites      bool
+	requests         chan struct{}
+	trace            *debugTrace
 }
 
 // authRoundTripper wrapper for authentication query params
@@ -65,43 +196,216 @@ type authRoundTripper struct {
 	delegate     http.RoundTripper
 	clientID     string
 	clientSecret string
+	scope        string
+	userAgent    string
 }
 
 func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if rt.delegate == nil {
-		rt.delegate = http.DefaultTransport
+	delegate := rt.delegate
+	if delegate == nil {
+		delegate = http.DefaultTransport
 	}
 	values := req.URL.Query()
 	values.Add("client_id", rt.clientID)
 	values.Add("client_secret", rt.clientSecret)
 	values.Add("grant_type", "client_credentials")
+	if rt.scope != "" {
+		values.Add("scope", rt.scope)
+	}
+	req = req.Clone(req.Context())
 	req.URL.RawQuery = values.Encode()
-	return rt.delegate.RoundTrip(req)
+	setAccept(req)
+	setUserAgent(req, rt.userAgent)
+	return delegate.RoundTrip(req)
+}
+
+// CloseIdleConnections closes any idle connections held by the delegate
+func (rt *authRoundTripper) CloseIdleConnections() {
+	closeIdleConnections(rt.delegate)
 }
 
-// restRoundTripper wrapper for adding bearer token
+// restRoundTripper wrapper for adding bearer token and handling gzip
+// compression
 type restRoundTripper struct {
 	delegate http.RoundTripper
-	token    string
+	// host is the host of the Marketo endpoint; the token is only sent
+	// to it, and not to the host of a redirect, such as a signed download
+	// URL
+	host             string
+	compressRequests bool
+	userAgent        string
+
+	// tokenLock guards token, which is replaced when the Client refreshes
+	// it while other requests are in flight
+	tokenLock sync.RWMutex
+	token     string
+}
+
+// setToken sets the token sent with requests
+func (rt *restRoundTripper) setToken(token string) {
+	rt.tokenLock.Lock()
+	defer rt.tokenLock.Unlock()
+	rt.token = token
 }
 
 func (rt *restRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if rt.delegate == nil {
-		rt.delegate = http.DefaultTransport
+	delegate := rt.delegate
+	if delegate == nil {
+		delegate = http.DefaultTransport
+	}
+	rt.tokenLock.RLock()
+	token := rt.token
+	rt.tokenLock.RUnlock()
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	if rt.host == "" || strings.EqualFold(req.URL.Host, rt.host) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Del("Authorization")
 	}
-	req.Header.Add("Authorization", "Bearer "+rt.token)
-	return rt.delegate.RoundTrip(req)
+	setAccept(req)
+	setUserAgent(req, rt.userAgent)
+
+	if rt.compressRequests && req.Body != nil &&
+		req.Header.Get("Content-Encoding") == "" &&
+		req.Header.Get("Content-Type") == "application/json" {
+		var err error
+		if req, err = gzipRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	// Setting Accept-Encoding explicitly disables the transparent
+	// decompression performed by http.Transport, so we always decompress
+	// here; this also covers custom transports which do not decompress.
+	decompress := false
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+		decompress = true
+	}
+
+	resp, err := delegate.RoundTrip(req)
+	if err != nil || !decompress {
+		return resp, err
+	}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if err = gunzipResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections closes any idle connections held by the delegate
+func (rt *restRoundTripper) CloseIdleConnections() {
+	closeIdleConnections(rt.delegate)
+}
+
+// closeIdleConnections closes the idle connections of rt, if it supports
+// doing so.
+func closeIdleConnections(rt http.RoundTripper) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if c, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// setAccept requests a JSON response unless the caller has specified
+// otherwise; without it some Marketo gateways return errors as XML or text.
+func setAccept(req *http.Request) {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+}
+
+// setUserAgent identifies the client to Marketo unless the caller has set a
+// User-Agent on the request.
+func setUserAgent(req *http.Request, userAgent string) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
+
+// gzipRequest replaces the body of req with its gzip compressed form
+func gzipRequest(req *http.Request) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	zw := gzip.NewWriter(body)
+	_, err := io.Copy(zw, req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := body.Bytes()
+	req.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	req.ContentLength = int64(len(compressed))
+	// the transport may rewind the body, ie to retry on a new connection,
+	// and must send the compressed body again
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	return req, nil
+}
+
+// gunzipResponse replaces the body of resp with a decompressing reader
+func gunzipResponse(resp *http.Response) error {
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = &gzipReadCloser{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
 }
 
+// gzipReadCloser closes both the gzip reader and the underlying body
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
+
+// RequestHook is called after each request to the REST API completes with
+// the name of the operation, the HTTP status code (0 if no response was
+// received), the time taken, and any error encountered.
+type RequestHook func(operation string, status int, dur time.Duration, err error)
+
 // ClientConfig stores client configuration
 type ClientConfig struct {
 	// ID: Marketo client ID
 	ID string
 	// Secret: Marketo client secret
 	Secret string
+	// Scope, optional: sent with the token request to scope the token
+	// to a specific Marketo user, where the subscription allows it
+	Scope string
 	// Endpoint: https://xxx-xxx-xxx.mktorest.com
 	Endpoint string
-	// Timeout, optional: default http timeout is 60 seconds
+	// Munchkin, optional: the Munchkin account ID of the Marketo
+	// instance, used in place of Endpoint to derive it; see
+	// MunchkinEndpoint
+	Munchkin string
+	// RESTVersion, optional: the REST API version, default is v1
+	RESTVersion string
+	// Timeout, optional: default http timeout is 60 seconds. The timeout
+	// applies to requests whose context has no deadline; a context
+	// deadline, when present, is used in its place and may be longer or
+	// shorter than Timeout.
 	Timeout uint
 	// Debug, optional: a flag to show logging output
 	Debug bool
@@ -111,71 +415,271 @@ type ClientConfig struct {
 	// RESTTransport, optional: the HTTP RoundTripper to use when
 	// making calls to the REST API.
 	RESTTransport http.RoundTripper
+	// IdleConnTimeout, optional: how long an idle connection to Marketo
+	// is kept open for reuse, default is the http.DefaultTransport's 90
+	// seconds. A timeout shorter than that of the load balancer in front
+	// of Marketo avoids reusing a connection it has already closed.
+	// Ignored for a custom AuthTransport or RESTTransport.
+	IdleConnTimeout time.Duration
+	// KeepAlive, optional: the interval between TCP keep-alive probes on
+	// connections to Marketo, default is the http.DefaultTransport's 30
+	// seconds; a negative value disables them. Ignored for a custom
+	// AuthTransport or RESTTransport. The transport used when either
+	// IdleConnTimeout or KeepAlive is set attempts HTTP/2, as the
+	// http.DefaultTransport does.
+	KeepAlive time.Duration
+	// TracerProvider, optional: when set, a span is started for each
+	// request made to the REST API.
+	TracerProvider TracerProvider
+	// OnRequest, optional: called after every request to the REST API,
+	// suitable for recording metrics.
+	OnRequest RequestHook
+	// DescribeCacheTTL, optional: when set, describe results are cached
+	// in memory and reused for this duration. The cache is shared by all
+	// the APIs created with the Client.
+	DescribeCacheTTL time.Duration
+	// CompressRequests, optional: when set, JSON request bodies are gzip
+	// compressed.
+	CompressRequests bool
+	// ValidateFilters, optional: when set, filter fields are validated
+	// against the searchable fields returned by describe before making
+	// the request. Combine with DescribeCacheTTL to avoid describing the
+	// object for every filter.
+	ValidateFilters bool
+	// UserAgent, optional: the User-Agent sent with every request,
+	// default is DefaultUserAgent
+	UserAgent string
+	// DisableAutoRefresh, optional: when set, the token is fetched when
+	// the Client is created but never refreshed automatically; requests
+	// made with an expired token return an error matching
+	// ErrAccessTokenExpired, and the caller must call RefreshToken.
+	DisableAutoRefresh bool
+	// MaxResponseBytes, optional: the largest response body read into
+	// memory, default is DefaultMaxResponseBytes. Larger responses
+	// return an error matching ErrResponseTooLarge. Responses streamed
+	// to the caller, such as export files, are not limited.
+	MaxResponseBytes int64
+	// GatewayRetries, optional: the number of times a request is
+	// retried, with backoff, when the gateway in front of Marketo
+	// returns a 502, 503 or 504 status, default is
+	// DefaultGatewayRetries. A negative value disables these retries.
+	// Only reads are retried unless RetryWrites is set, and requests
+	// whose body cannot be rewound are not retried.
+	GatewayRetries int
+	// RetryWrites, optional: when set, requests which may modify data,
+	// such as syncs, imports and campaign triggers, are also retried
+	// after a gateway error. Marketo may have processed a request the
+	// gateway failed, so retrying a write can create duplicate records.
+	RetryWrites bool
+	// MaxConcurrentRequests, optional: the most requests the Client makes
+	// to the REST API at once; further requests wait for one to complete.
+	// Marketo rejects more than 10 concurrent requests for an instance
+	// with ErrConcurrentLimitReached. The default is no limit.
+	MaxConcurrentRequests int
+	// TokenSource, optional: supplies the access tokens used in place of
+	// requesting them with the ID and Secret; see EnvTokenSource and
+	// FileTokenSource
+	TokenSource TokenSource
+	// DebugTraceSize, optional: when set, the most recent DebugTraceSize
+	// requests to the REST API are retained, with secrets redacted, and
+	// returned by DebugTrace
+	DebugTraceSize int
+}
+
+// userAgent returns the configured UserAgent or DefaultUserAgent
+func (config ClientConfig) userAgent() string {
+	if config.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return config.UserAgent
+}
+
+// timeout returns the configured Timeout or DefaultTimeout
+func (config ClientConfig) timeout() time.Duration {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return time.Second * time.Duration(timeout)
+}
+
+// maxResponseBytes returns the configured MaxResponseBytes or
+// DefaultMaxResponseBytes
+func (config ClientConfig) maxResponseBytes() int64 {
+	if config.MaxResponseBytes <= 0 {
+		return DefaultMaxResponseBytes
+	}
+	return config.MaxResponseBytes
+}
+
+// transport returns rt, or if it is nil and IdleConnTimeout or KeepAlive are
+// set, a copy of the http.DefaultTransport configured with them. A nil
+// RoundTripper uses the http.DefaultTransport, as does a replaced
+// http.DefaultTransport which cannot be copied.
+func (config ClientConfig) transport(rt http.RoundTripper) http.RoundTripper {
+	if rt != nil || (config.IdleConnTimeout == 0 && config.KeepAlive == 0) {
+		return rt
+	}
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	t := defaultTransport.Clone()
+	if config.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.KeepAlive != 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: config.KeepAlive,
+		}).DialContext
+	}
+	t.ForceAttemptHTTP2 = true
+	return t
 }
 
 // NewClient returns a new Marketo Client
 func NewClient(config ClientConfig) (*Client, error) {
-	// create two roundtrippers
-	aRT := authRoundTripper{
-		clientID:     config.ID,
-		clientSecret: config.Secret,
-		delegate:     config.AuthTransport,
+	endpoint, err := configEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenSource := config.TokenSource
+	if tokenSource == nil {
+		tokenSource = newClientCredentials(config, endpoint)
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
 	}
 	rRT := restRoundTripper{
-		delegate: config.RESTTransport,
+		delegate:         config.transport(config.RESTTransport),
+		host:             endpointURL.Host,
+		compressRequests: config.CompressRequests,
+		userAgent:        config.userAgent(),
 	}
 
-	timeout := config.Timeout
-	if timeout == 0 {
-		timeout = DefaultTimeout
+	restVersion := config.RESTVersion
+	if restVersion == "" {
+		restVersion = DefaultRESTVersion
+	}
+
+	gatewayRetries := config.GatewayRetries
+	if gatewayRetries == 0 {
+		gatewayRetries = DefaultGatewayRetries
 	}
 	// Add credentials to the request
 	c := &Client{
-		authClient: &http.Client{
-			Timeout:   time.Second * time.Duration(timeout),
-			Transport: &aRT,
-		},
+		tokenSource: tokenSource,
 		restClient: &http.Client{
-			Timeout:   time.Second * time.Duration(timeout),
+			Timeout:   config.timeout(),
 			Transport: &rRT,
 		},
 		restRoundTripper: &rRT,
-		endpoint:         config.Endpoint,
-		identityEndpoint: config.Endpoint + identityBase + identityPath,
+		endpoint:         endpoint,
+		restVersion:      restVersion,
 		debug:            config.Debug,
+		onRequest:        config.OnRequest,
+		describe:         newDescribeCache(config.DescribeCacheTTL),
+		validateFilters:  config.ValidateFilters,
+		disableRefresh:   config.DisableAutoRefresh,
+		maxResponseBytes: config.maxResponseBytes(),
+		gatewayRetries:   gatewayRetries,
+		retryWrites:      config.RetryWrites,
+		trace:            newDebugTrace(config.DebugTraceSize),
+	}
+	if config.MaxConcurrentRequests > 0 {
+		c.requests = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+	if config.TracerProvider != nil {
+		c.tracer = config.TracerProvider.Tracer(tracerName)
 	}
 
 	if _, err := c.RefreshToken(); err != nil {
-		return nil, errors.New("Unable to refresh Marketo auth token")
+		return nil, err
 	}
 	return c, nil
 }
 
+// Close releases the idle connections held by the Client's transports. The
+// Client may still be used after Close, although new connections will need to
+// be established.
+func (c *Client) Close() error {
+	if ts, ok := c.tokenSource.(interface{ CloseIdleConnections() }); ok {
+		ts.CloseIdleConnections()
+	}
+	c.restClient.CloseIdleConnections()
+	return nil
+}
+
+// Transport returns an http.RoundTripper which sends requests using the
+// Client: the access token is refreshed as needed and attached to requests
+// for the Client's endpoint, requests rejected with an expired or invalid
+// token (601 or 602) are retried once with a new token, and transient gateway
+// errors are retried as configured. This allows requests the Client does not
+// provide a method for to be made with an existing http.Client.
+//
+// Responses with a JSON body are read into memory to check for token errors,
+// and so are subject to MaxResponseBytes; other responses are streamed.
+func (c *Client) Transport() http.RoundTripper {
+	return clientTransport{c}
+}
+
+// clientTransport is the http.RoundTripper returned by Client.Transport.
+type clientTransport struct {
+	c *Client
+}
+
+func (t clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request, and doRequest rewinds the
+	// body of requests which are retried; the caller's body may not be held
+	// in memory, so it is not traced
+	req = withUnbufferedBody(req.Clone(req.Context()))
+	return t.c.doRequest(strings.ToLower(req.Method), req)
+}
+
+// withTimeout returns ctx limited to timeout, if it is set; the earlier of
+// the timeout and any existing deadline applies.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// withDeadline returns the http.Client used to make req. The client-wide
+// Timeout applies only to requests whose context has no deadline; when the
+// context has a deadline it takes precedence, so that a long running upload
+// is not cut off by the Timeout.
+func withDeadline(client *http.Client, req *http.Request) *http.Client {
+	if _, ok := req.Context().Deadline(); !ok || client.Timeout == 0 {
+		return client
+	}
+	c := *client
+	c.Timeout = 0
+	return &c
+}
+
 // RefreshToken refreshes the auth token.
 // This is purely for testing purpose and not intended to be used.
 func (c *Client) RefreshToken() (auth AuthToken, err error) {
+	return c.RefreshTokenContext(context.Background())
+}
+
+// RefreshTokenContext refreshes the auth token, aborting if ctx is cancelled
+// or its deadline passes first.
+func (c *Client) RefreshTokenContext(ctx context.Context) (auth AuthToken, err error) {
 	if c.debug {
 		log.Printf("[marketo/RefreshToken] start")
 		defer func() {
 			log.Print("[marketo/RefreshToken] DONE")
 		}()
 	}
-	// Make request for token
-	resp, err := c.authClient.Get(c.identityEndpoint)
+	auth, expiresAt, err := c.tokenSource.Token(ctx)
 	if err != nil {
-		return auth, errors.New("Unable to get Market auth token")
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return auth, errors.New("Server error getting marketo auth token")
-		}
-		return auth, fmt.Errorf("authentication error: %d %s", resp.StatusCode, body)
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
-		return auth, errors.New("Unable to decode marketo error token")
+		return auth, err
 	}
 	if c.debug {
 		log.Printf("[marketo/RefreshToken] New token: %v", auth)
@@ -183,8 +687,8 @@ func (c *Client) RefreshToken() (auth AuthToken, err error) {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 	c.auth = &auth
-	c.restRoundTripper.token = auth.AccessToken
-	c.tokenExpiresAt = time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	c.restRoundTripper.setToken(auth.AccessToken)
+	c.tokenExpiresAt = expiresAt
 	return auth, nil
 }
 
@@ -192,7 +696,12 @@ func (c *Client) url(paths ...string) string {
 	return fmt.Sprintf("%s/%s", c.endpoint, strings.Join(paths, "/"))
 }
 
-func (c *Client) do(req *http.Request) (response *Response, err error) {
+// restURL returns the url for paths in the configured REST API version
+func (c *Client) restURL(paths ...string) string {
+	return c.url(append([]string{"rest", c.restVersion}, paths...)...)
+}
+
+func (c *Client) do(operation string, req *http.Request) (response *Response, err error) {
 	var body []byte
 	if c.debug {
 		log.Printf("[marketo/do] URL: %s", req.URL)
@@ -200,138 +709,448 @@ func (c *Client) do(req *http.Request) (response *Response, err error) {
 			log.Printf("[marketo/do] DONE: body %s", string(body))
 		}()
 	}
-	resp, err := c.restClient.Do(req)
+	req, span := c.startSpan(operation, req)
+	start, status := time.Now(), 0
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+		if c.onRequest != nil {
+			c.onRequest(operation, status, time.Since(start), err)
+		}
+		c.recordTrace(operation, req, status, body, start, err)
+	}()
+
+	resp, err := c.roundTrip(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", operation, err)
 	}
 	defer resp.Body.Close()
+	status = resp.StatusCode
+	span.SetAttribute("http.status_code", status)
 
-	body, err = ioutil.ReadAll(resp.Body)
+	body, err = readBody(resp.Body, c.maxResponseBytes)
 	if err != nil {
-		return nil, err
+		return nil, statusError{operation: operation, status: resp.StatusCode, err: err}
 	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Unexpected status code[%d] with body[%s]", resp.StatusCode, string(body))
+		errResponse := Response{}
+		if json.Unmarshal(body, &errResponse) == nil && len(errResponse.Errors) > 0 {
+			recordReasons(span, errResponse.Errors)
+			return nil, responseError(operation, resp.StatusCode, &errResponse)
+		}
+		return nil, Error{
+			Operation:  operation,
+			Message:    fmt.Sprintf("Unexpected status code[%d] with body[%s]", resp.StatusCode, string(body)),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
 	}
 
 	if len(body) == 0 {
-		return nil, fmt.Errorf("No body! Check URL: %s", req.URL)
+		return nil, Error{
+			Operation:  operation,
+			Message:    fmt.Sprintf("No body! Check URL: %s", req.URL),
+			StatusCode: resp.StatusCode,
+		}
 	}
 
+	response = &Response{}
 	err = json.Unmarshal(body, response)
-	if err != nil || response == nil {
-		response = &Response{
-			Raw: body,
+	response.Raw = body
+	recordReasons(span, response.Errors)
+
+	return response, err
+}
+
+// ensureToken refreshes the auth token if it has expired
+func (c *Client) ensureToken(ctx context.Context) error {
+	c.authLock.Lock()
+	expiresAt := c.tokenExpiresAt
+	c.authLock.Unlock()
+	if !expiresAt.Before(time.Now()) {
+		return nil
+	}
+
+	if c.debug {
+		log.Printf("[marketo/ensureToken] token expired at: %s", expiresAt.String())
+	}
+	if c.disableRefresh {
+		return ErrorForReasons(0, Reason{
+			Code:    ErrAccessTokenExpired.Code,
+			Message: "Access token expired",
+		})
+	}
+	_, err := c.RefreshTokenContext(ctx)
+	return err
+}
+
+func (c *Client) doWithRetry(operation string, req *http.Request) (response *Response, err error) {
+	// check if token has been expired or not
+	if err := c.ensureToken(req.Context()); err != nil {
+		return nil, err
+	}
+
+	response, err = c.do(operation, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// there's no point retrying once the daily quota is exhausted
+	if err := quotaError(operation, response); err != nil {
+		return nil, err
+	}
+
+	// check just in case we received 601 or 602
+	retry, err := c.checkToken(req.Context(), response)
+	if err != nil {
+		return nil, err
+	}
+	if retry {
+		if ok, err := rewind(req); err != nil || !ok {
+			if err == nil {
+				err = responseError(operation, http.StatusOK, response)
+			}
+			return nil, err
 		}
+		response, err = c.do(operation, req)
 	}
-	
+
 	return response, err
 }
 
-func (c *Client) doWithRetry(req *http.Request) (response *Response, err error) {
-	// check if token has been expired or not
-	if c.tokenExpiresAt.Before(time.Now()) {
+// roundTrip sends req to the REST API, retrying with backoff while the
+// gateway in front of Marketo returns a transient 502, 503 or 504 status.
+// Writes are only retried if the Client is configured with RetryWrites.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	client := withDeadline(c.restClient, req)
+	retries := c.gatewayRetries
+	if !c.retryWrites && !isRead(req) {
+		retries = 0
+	}
+	wait := gatewayBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.limit(req, client.Do)
+		if err != nil || !isGatewayError(resp.StatusCode) || attempt >= retries {
+			return resp, err
+		}
+		if ok, err := rewind(req); err != nil || !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+
 		if c.debug {
-			log.Printf("[marketo/doWithRetry] token expired at: %s", c.tokenExpiresAt.String())
+			log.Printf("[marketo/roundTrip] status %d, retrying in %s", resp.StatusCode, wait)
 		}
-		c.RefreshToken()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// limit makes req using do once fewer than MaxConcurrentRequests requests
+// are in flight, if the Client is configured with a limit.
+func (c *Client) limit(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if c.requests == nil {
+		return do(req)
+	}
+	select {
+	case c.requests <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	defer func() { <-c.requests }()
+	return do(req)
+}
+
+// isRead returns true if req only reads data; Marketo accepts reads with
+// long query strings as a POST with the _method=GET parameter.
+func isRead(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return req.URL.Query().Get("_method") == http.MethodGet
+	}
+	return false
+}
+
+// isGatewayError returns true if status is returned by the gateway in front
+// of Marketo when it is temporarily unable to reach the API
+func isGatewayError(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// rewind resets the body of req so that it can be sent again, returning false
+// if the body has been consumed and cannot be reset.
+func rewind(req *http.Request) (bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true, nil
+	}
+	if req.GetBody == nil {
+		return false, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false, err
+	}
+	req.Body = body
+	return true, nil
+}
+
+func (c *Client) doRequest(operation string, req *http.Request) (response *http.Response, err error) {
+	// check if token has been expired or not
+	if err := c.ensureToken(req.Context()); err != nil {
+		return nil, err
 	}
 
-	response, err = c.do(req)
+	response, envelope, err := c.send(operation, req)
 	if err != nil {
 		return nil, err
 	}
+	if envelope == nil {
+		return response, nil
+	}
 
 	// check just in case we received 601 or 602
-	retry, err := c.checkToken(response)
+	retry, err := c.checkToken(req.Context(), envelope)
 	if err != nil {
+		response.Body.Close()
 		return nil, err
 	}
 	if retry {
-		response, err = c.do(req)
+		response.Body.Close()
+		if ok, err := rewind(req); err != nil || !ok {
+			if err == nil {
+				err = responseError(operation, response.StatusCode, envelope)
+			}
+			return nil, err
+		}
+		response, _, err = c.send(operation, req)
 	}
 
 	return response, err
 }
 
-func (c *Client) doRequest(req *http.Request) (response *http.Response, err error) {
-	// check if token has been expired or not
-	if c.tokenExpiresAt.Before(time.Now()) {
-		if c.debug {
-			log.Printf("[marketo/doWithRetry] token expired at: %s", c.tokenExpiresAt.String())
+// send performs a single request to the REST API. If the response contains a
+// JSON body or is not successful, the body is read and the parsed envelope is
+// returned along with the response; the response body is left intact for the
+// caller, who is responsible for closing it.
+func (c *Client) send(operation string, req *http.Request) (response *http.Response, envelope *Response, err error) {
+	req, span := c.startSpan(operation, req)
+	start, status := time.Now(), 0
+	var body []byte
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
 		}
-		c.RefreshToken()
+		span.End()
+		if c.onRequest != nil {
+			c.onRequest(operation, status, time.Since(start), err)
+		}
+		c.recordTrace(operation, req, status, body, start, err)
+	}()
+
+	response, err = c.roundTrip(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", operation, err)
+	}
+	status = response.StatusCode
+	span.SetAttribute("http.status_code", status)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 && !isJSON(response) {
+		return response, nil, nil
 	}
 
-	response, err = c.restClient.Do(req)
+	body, err = readBody(response.Body, c.maxResponseBytes)
+	response.Body.Close()
+	if err != nil {
+		return nil, nil, statusError{operation: operation, status: response.StatusCode, err: err}
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	envelope = &Response{}
+	if json.Unmarshal(body, envelope) != nil {
+		return response, nil, nil
+	}
+	recordReasons(span, envelope.Errors)
+	return response, envelope, nil
+}
+
+// readBody reads r into memory, returning ErrResponseTooLarge if it contains
+// more than limit bytes
+func readBody(r io.Reader, limit int64) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
 	if err != nil {
 		return nil, err
 	}
+	if int64(len(body)) > limit {
+		return nil, ErrResponseTooLarge
+	}
+	return body, nil
+}
 
-	return response, err
+// isJSON returns true if the response Content-Type is JSON
+func isJSON(response *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	return mediaType == "application/json"
 }
 
-func (c *Client) checkToken(response *Response) (retry bool, err error) {
+// quotaError returns an Error if the response indicates the daily quota has
+// been reached.
+func quotaError(operation string, response *Response) error {
+	for _, r := range response.Errors {
+		if r.Code == ErrDailyQuotaReached.Code {
+			return responseError(operation, http.StatusOK, response)
+		}
+	}
+	return nil
+}
+
+func (c *Client) checkToken(ctx context.Context, response *Response) (retry bool, err error) {
 	if len(response.Errors) > 0 && (response.Errors[0].Code == "601" || response.Errors[0].Code == "602") {
 		retry = true
 		if c.debug {
 			log.Printf("[marketo/checkToken] Expired/invalid token: %s", response.Errors[0].Code)
 		}
-		_, err = c.RefreshToken()
+		if c.disableRefresh {
+			return false, ErrorForReasons(http.StatusOK, response.Errors...)
+		}
+		_, err = c.RefreshTokenContext(ctx)
 	}
 	if err != nil {
-		return retry, errors.New("Invalid/Expired Marketo Auth Token")
+		return retry, fmt.Errorf("refreshing invalid or expired token: %w", err)
 	}
 	return retry, nil
 }
 
-// Get performs an HTTP GET for the specified resource url
-func (c *Client) Get(resource string) (response *Response, err error) {
-	if c.debug {
-		log.Printf("[marketo/Get] %s", resource)
-		defer func() {
-			log.Print("[marketo/Get] DONE")
-		}()
-	}
-	req, err := http.NewRequest("GET", c.endpoint+resource, nil)
-	if err != nil {
-		return nil, err
+// RequestOption defines the signature of functional options applied to the
+// requests made by the generic client methods.
+type RequestOption func(*http.Request)
+
+// WithHeader sets a header on the request. The Authorization header is always
+// set by the Client and may not be overridden.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
 	}
-	return c.doWithRetry(req)
 }
 
-// Post performs an HTTP POST to the specified resource url with given data
-func (c *Client) Post(resource string, data []byte) (response *Response, err error) {
+// Do performs an HTTP request with the given method to the specified resource
+// url, with optional body and headers. Expired or invalid tokens are
+// refreshed and the request retried.
+func (c *Client) Do(method, resource string, body []byte, headers http.Header, opts ...RequestOption) (response *Response, err error) {
 	if c.debug {
-		log.Printf("[marketo/Post] %s, %s", resource, string(data))
+		log.Printf("[marketo/Do] %s %s, %s", method, resource, string(body))
 		defer func() {
-			log.Print("[marketo/Post] DONE")
+			log.Printf("[marketo/Do] %s DONE", method)
 		}()
 	}
-	req, err := http.NewRequest("POST", c.endpoint+resource, bytes.NewBuffer(data))
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.endpoint+resource, reader)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	for k, vv := range headers {
+		req.Header.Del(k)
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
 
-	return c.doWithRetry(req)
+	return c.doWithRetry(strings.ToLower(method), req)
 }
 
-// Delete sends an HTTP DELETE request to specified resource url with given data
-func (c *Client) Delete(resource string, data []byte) (response *Response, err error) {
+// Stream performs an HTTP request with the given method to the specified
+// resource url, returning the response with its body unread. Expired or
+// invalid tokens are refreshed and the request retried; a non-2xx response is
+// returned as an error. The request is only retried if body is nil, an
+// in-memory reader, or an io.Seeker which can be rewound; otherwise the token
+// error is returned and the caller must make the request again. The caller
+// is responsible for closing the response body.
+func (c *Client) Stream(ctx context.Context, method, resource string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
 	if c.debug {
-		log.Printf("[marketo/Delete] %s, %s", resource, string(data))
-		defer func() {
-			log.Print("[marketo/Delete] DONE")
-		}()
+		log.Printf("[marketo/Stream] %s %s", method, resource)
 	}
-	req, err := http.NewRequest("DELETE", c.endpoint+resource, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+resource, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if seeker, ok := body.(io.ReadSeeker); ok && req.GetBody == nil {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(seeker), nil
+		}
+		req = withUnbufferedBody(req)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	operation := strings.ToLower(method)
+	resp, err := c.doRequest(operation, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, handleError(operation, resp)
+	}
+
+	return resp, nil
+}
+
+// jsonHeaders are sent with requests which include a JSON body
+func jsonHeaders() http.Header {
+	return http.Header{"Content-Type": {"application/json"}}
+}
+
+// Get performs an HTTP GET for the specified resource url
+func (c *Client) Get(resource string, opts ...RequestOption) (response *Response, err error) {
+	return c.Do(http.MethodGet, resource, nil, nil, opts...)
+}
+
+// Post performs an HTTP POST to the specified resource url with given data
+func (c *Client) Post(resource string, data []byte, opts ...RequestOption) (response *Response, err error) {
+	return c.Do(http.MethodPost, resource, data, jsonHeaders(), opts...)
+}
+
+// PostForm performs an HTTP POST to the specified resource url with the
+// given values form-encoded as the body
+func (c *Client) PostForm(resource string, values url.Values, opts ...RequestOption) (response *Response, err error) {
+	return c.Do(
+		http.MethodPost, resource, []byte(values.Encode()),
+		http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		opts...,
+	)
+}
 
-	return c.doWithRetry(req)
+// Put performs an HTTP PUT to the specified resource url with given data
+func (c *Client) Put(resource string, data []byte, opts ...RequestOption) (response *Response, err error) {
+	return c.Do(http.MethodPut, resource, data, jsonHeaders(), opts...)
+}
+
+// Delete sends an HTTP DELETE request to specified resource url with given data
+func (c *Client) Delete(resource string, data []byte, opts ...RequestOption) (response *Response, err error) {
+	return c.Do(http.MethodDelete, resource, data, jsonHeaders(), opts...)
 }
 
 // TokenInfo holds authentication token and time at which expires.
@@ -340,9 +1159,49 @@ type TokenInfo struct {
 	Token string
 	// Expires shows what time the token expires
 	Expires time.Time
+	// ExpiresIn is the lifetime of the token, in seconds, when issued
+	ExpiresIn int
+	// Scope is the scope granted to the token
+	Scope string
 }
 
-// GetTokenInfo returns current TokenInfo stored in Client
-func (c *Client) GetTokenInfo() TokenInfo {
-	return TokenInfo{c.auth.AccessToken, c.tokenExpiresAt}
+// HasScope returns true if s is one of the space or comma separated scopes
+// granted to the token.
+func (t TokenInfo) HasScope(s string) bool {
+	scopes := strings.FieldsFunc(t.Scope, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	for _, scope := range scopes {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTokenInfo returns current TokenInfo stored in Client; ok is false if no
+// token has been fetched yet.
+func (c *Client) GetTokenInfo() (info TokenInfo, ok bool) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	if c.auth == nil {
+		return info, false
+	}
+	return TokenInfo{
+		Token:     c.auth.AccessToken,
+		Expires:   c.tokenExpiresAt,
+		ExpiresIn: c.auth.ExpiresIn,
+		Scope:     c.auth.Scope,
+	}, true
+}
+
+// NextRefreshAt returns the time the current token expires, after which the
+// next request refreshes it unless DisableAutoRefresh is set. Services with
+// little traffic may call RefreshTokenContext shortly before then so that a
+// request never waits for a refresh. It returns the zero time if no token has
+// been fetched yet.
+func (c *Client) NextRefreshAt() time.Time {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.tokenExpiresAt
 }
@@ -1,14 +1,24 @@
 package marketo
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
 )
 
 const (
@@ -92,7 +102,10 @@ func TestNewClientSuccess(t *testing.T) {
 	}
 
 	// check token
-	tokenInfo := client.GetTokenInfo()
+	tokenInfo, ok := client.GetTokenInfo()
+	if !ok {
+		t.Errorf("Expected token to be fetched")
+	}
 	if tokenInfo.Token != token {
 		t.Errorf("Expected %s to equal %s", token, tokenInfo.Token)
 	}
@@ -143,10 +156,14 @@ func TestNewClientError(t *testing.T) {
 
 	_, err := NewClient(config)
 	if err != nil {
-		expectedError := fmt.Sprintf("authentication error: 401 %s", authResponseError)
+		expectedError := "authentication error: 401 invalid_client: Bad client credentials"
 		if err.Error() != expectedError {
 			t.Errorf("Expected response:\n%s\n%s", expectedError, err)
 		}
+		var authErr AuthError
+		if !errors.As(err, &authErr) || authErr.Code != "invalid_client" {
+			t.Errorf("Expected AuthError: %#v", err)
+		}
 
 		if called != 1 {
 			t.Errorf("Expected only one call: %d", called)
@@ -199,7 +216,10 @@ func TestRefreshToken(t *testing.T) {
 	}
 
 	// check token
-	tokenInfo := client.GetTokenInfo()
+	tokenInfo, ok := client.GetTokenInfo()
+	if !ok {
+		t.Errorf("Expected token to be fetched")
+	}
 	if tokenInfo.Token != tokens[0] {
 		t.Errorf("Expected %s to equal %s", tokens[0], tokenInfo.Token)
 	}
@@ -228,10 +248,16 @@ func TestRefreshToken(t *testing.T) {
 	}
 
 	// check token again
-	tokenInfo = client.GetTokenInfo()
+	tokenInfo, _ = client.GetTokenInfo()
 	if tokenInfo.Token != tokens[1] {
 		t.Errorf("Expected %s to equal %s", tokens[1], tokenInfo.Token)
 	}
+	if !client.NextRefreshAt().Equal(tokenInfo.Expires) {
+		t.Errorf("Expected next refresh at %s, got %s", tokenInfo.Expires, client.NextRefreshAt())
+	}
+	if until := time.Until(client.NextRefreshAt()); until < 3590*time.Second || until > 3599*time.Second {
+		t.Errorf("Expected next refresh in 3599s, got %s", until)
+	}
 }
 
 const (
@@ -277,10 +303,13 @@ func TestGetErrorWith500(t *testing.T) {
 		if called != 2 {
 			t.Errorf("Expected only two calls: %d", called)
 		}
-		expectedError := "Unexpected status code[500] with body[Internal server error]"
+		expectedError := "get: Unexpected status code[500] with body[Internal server error]"
 		if err.Error() != expectedError {
 			t.Errorf("Expected %s, got %s", expectedError, err)
 		}
+		if status, ok := HTTPStatus(err); !ok || status != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d", status)
+		}
 		return
 	}
 	t.Error("Expectation not met")
@@ -315,7 +344,7 @@ func TestGetErrorWithNoBody(t *testing.T) {
 		if called != 2 {
 			t.Errorf("Expected only two calls: %d", called)
 		}
-		expectedError := fmt.Sprintf("No body! Check URL: %s%s", ts.URL, invalidFindLeadPath)
+		expectedError := fmt.Sprintf("get: No body! Check URL: %s%s", ts.URL, invalidFindLeadPath)
 		if err.Error() != expectedError {
 			t.Errorf("Expected %s, got %s", expectedError, err)
 		}
@@ -829,3 +858,1017 @@ func TestPostSuccess(t *testing.T) {
 		t.Errorf("Expected only two calls: %d", called)
 	}
 }
+
+func TestOnRequest(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusInternalServerError).
+		BodyString("Internal server error")
+
+	type call struct {
+		operation string
+		status    int
+		err       error
+	}
+	calls := []call{}
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+		OnRequest: func(operation string, status int, dur time.Duration, err error) {
+			calls = append(calls, call{operation, status, err})
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = NewLeadAPI(client).DescribeFields(context.Background())
+	require.NoError(t, err)
+	_, err = client.Get("/rest/v1/leads.json")
+	require.Error(t, err)
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, call{describeLead2, http.StatusOK, nil}, calls[0])
+	assert.Equal(t, "get", calls[1].operation)
+	assert.Equal(t, http.StatusInternalServerError, calls[1].status)
+	assert.Error(t, calls[1].err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGetTokenInfoWithoutToken(t *testing.T) {
+	client := &Client{}
+
+	tokenInfo, ok := client.GetTokenInfo()
+	assert.False(t, ok)
+	assert.Equal(t, TokenInfo{}, tokenInfo)
+}
+
+func TestGzip(t *testing.T) {
+	called := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(authResponseSuccess, token)))
+			return
+		}
+
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(zr)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"input":[]}`, string(body))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(getResponseSuccess))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         ts.URL,
+		CompressRequests: true,
+	})
+	require.NoError(t, err)
+
+	response, err := client.Get(findLeadPath)
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+
+	response, err = client.Post("/rest/v1/leads.json", []byte(`{"input":[]}`))
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Equal(t, 3, called)
+}
+
+func TestGzipRequest_getBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, testHost+"/rest/v1/leads.json", strings.NewReader(`{"input":[]}`))
+	require.NoError(t, err)
+	req, err = gzipRequest(req)
+	require.NoError(t, err)
+
+	// both the body and a rewound body are compressed
+	for _, body := range []func() (io.ReadCloser, error){
+		func() (io.ReadCloser, error) { return req.Body, nil },
+		req.GetBody,
+	} {
+		rc, err := body()
+		require.NoError(t, err)
+		compressed, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(compressed), req.ContentLength)
+
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		plain, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, `{"input":[]}`, string(plain))
+	}
+}
+
+func TestRefreshTokenContext(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseExpiringSuccess, token))
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.RefreshTokenContext(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// an expired token is refreshed using the request's context
+	time.Sleep(1100 * time.Millisecond)
+	_, err = NewLeadAPI(client).DescribeFields(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, gock.IsDone())
+}
+
+func TestGetDailyQuotaReached(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1000","success":false,"errors":[{"code":"607","message":"Daily quota reached"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	response, err := client.Get(findLeadPath)
+	assert.Nil(t, response)
+	assert.True(t, errors.Is(err, ErrDailyQuotaReached))
+	assert.False(t, err.(Error).Retryable())
+	assert.True(t, gock.IsDone())
+}
+
+func TestPutSuccess(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Put("/rest/asset/v1/folder/1.json").
+		MatchHeader("Content-Type", "application/json").
+		BodyString(`{"name":"test"}`).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1000","success":true,"result":[]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	response, err := client.Put("/rest/asset/v1/folder/1.json", []byte(`{"name":"test"}`))
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDo(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Patch("/rest/v1/thing.json").
+		MatchHeader("X-Custom", "value").
+		BodyString(`{"a":1}`).
+		Reply(http.StatusOK).
+		JSON(invalidTokenResponse)
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Patch("/rest/v1/thing.json").
+		BodyString(`{"a":1}`).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1001","success":true}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	response, err := client.Do(
+		http.MethodPatch, "/rest/v1/thing.json", []byte(`{"a":1}`),
+		http.Header{"X-Custom": {"value"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "1001", response.RequestID)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDo_canonicalHeaders(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Post("/rest/v1/thing.json").
+		AddMatcher(func(r *http.Request, _ *gock.Request) (bool, error) {
+			_, lower := r.Header["content-type"]
+			return !lower &&
+				assert.Equal(t, []string{"text/csv"}, r.Header["Content-Type"]) &&
+				assert.Equal(t, []string{"a", "b"}, r.Header["X-Custom"]), nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1001","success":true}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Do(
+		http.MethodPost, "/rest/v1/thing.json", []byte("a,b"),
+		http.Header{"content-type": {"text/csv"}, "x-custom": {"a", "b"}},
+	)
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestPostForm(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		MatchHeader("Content-Type", "application/x-www-form-urlencoded").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "email", r.PostForm.Get("filterType"))
+			assert.Equal(t, "tester@example.com", r.PostForm.Get("filterValues"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		JSON(getResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	response, err := client.PostForm("/rest/v1/leads.json?_method=GET", url.Values{
+		"filterType":   {"email"},
+		"filterValues": {"tester@example.com"},
+	})
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.True(t, gock.IsDone())
+}
+
+func TestStream(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/bulk/v1/leads/export/1/file.json").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "text/csv").
+		BodyString("email\ntester@example.com\n")
+	gock.New(testHost).
+		Get("/bulk/v1/leads/export/2/file.json").
+		Reply(http.StatusNotFound).
+		JSON(`{"success":false,"errors":[{"code":"610","message":"Not found"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	resp, err := client.Stream(context.Background(), http.MethodGet, "/bulk/v1/leads/export/1/file.json", nil)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "email\ntester@example.com\n", string(body))
+
+	_, err = client.Stream(context.Background(), http.MethodGet, "/bulk/v1/leads/export/2/file.json", nil)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, gock.IsDone())
+}
+
+func TestPartitionResults(t *testing.T) {
+	results := []RecordResult{
+		{ID: 1, Status: RecordCreated},
+		{Status: RecordSkipped, Reasons: []Reason{{Code: "1005", Message: "Lead already exists"}}},
+		{ID: 2, Status: RecordUpdated},
+		{Status: RecordFailed},
+	}
+
+	succeeded, failed := PartitionResults(results)
+	assert.Equal(t, []RecordResult{results[0], results[2]}, succeeded)
+	assert.Equal(t, []RecordResult{results[1], results[3]}, failed)
+	assert.False(t, results[0].Failed())
+	assert.True(t, results[1].Failed())
+}
+
+func TestSummarizeResults(t *testing.T) {
+	summary := SummarizeResults([]RecordResult{
+		{ID: 1, Status: RecordCreated},
+		{ID: 2, Status: RecordCreated},
+		{ID: 3, Status: RecordUpdated},
+		{Status: RecordSkipped},
+		{Status: RecordFailed},
+		{ID: 4},
+	})
+
+	assert.Equal(t, ResultSummary{Created: 2, Updated: 1, Skipped: 1, Failed: 1, Other: 1}, summary)
+	assert.Equal(t, 6, summary.Total())
+}
+
+func TestAcceptHeader(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		MatchHeader("Accept", "application/json").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		MatchHeader("Accept", "application/json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		MatchHeader("Accept", "application/json").
+		Reply(http.StatusOK).
+		JSON(getResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = NewLeadAPI(client).DescribeFields(context.Background())
+	require.NoError(t, err)
+	_, err = client.Get("/rest/v1/leads.json")
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestTokenInfoScope(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	info, ok := client.GetTokenInfo()
+	require.True(t, ok)
+	assert.Equal(t, 3599, info.ExpiresIn)
+	assert.Equal(t, "tester@example.com", info.Scope)
+	assert.True(t, info.HasScope("tester@example.com"))
+	assert.False(t, info.HasScope("other@example.com"))
+
+	info.Scope = "read_only, write lead"
+	assert.True(t, info.HasScope("write"))
+	assert.True(t, info.HasScope("read_only"))
+	assert.False(t, info.HasScope("read"))
+}
+
+func TestUserAgent(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			MatchHeader("User-Agent", "^go-marketo").
+			Reply(http.StatusOK).
+			JSON(fmt.Sprintf(authResponseSuccess, token))
+
+		_, err := NewClient(ClientConfig{
+			ID:       clientID,
+			Secret:   clientSecret,
+			Endpoint: testHost,
+		})
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			MatchHeader("User-Agent", "^polytomic/1.0$").
+			Reply(http.StatusOK).
+			JSON(fmt.Sprintf(authResponseSuccess, token))
+		gock.New(testHost).
+			Get("/rest/v1/leads.json").
+			MatchHeader("User-Agent", "^polytomic/1.0$").
+			Reply(http.StatusOK).
+			JSON(getResponseSuccess)
+
+		client, err := NewClient(ClientConfig{
+			ID:        clientID,
+			Secret:    clientSecret,
+			Endpoint:  testHost,
+			UserAgent: "polytomic/1.0",
+		})
+		require.NoError(t, err)
+
+		_, err = client.Get("/rest/v1/leads.json")
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+type closingTransport struct {
+	closed int
+}
+
+func (t *closingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (t *closingTransport) CloseIdleConnections() {
+	t.closed++
+}
+
+func TestClose(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+
+	auth, rest := &closingTransport{}, &closingTransport{}
+	client, err := NewClient(ClientConfig{
+		ID:            clientID,
+		Secret:        clientSecret,
+		Endpoint:      testHost,
+		AuthTransport: auth,
+		RESTTransport: rest,
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, client.Close())
+	assert.Equal(t, 1, auth.closed)
+	assert.Equal(t, 1, rest.closed)
+}
+
+func TestContextDeadlineOverridesTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Write([]byte(fmt.Sprintf(authResponseSuccess, token)))
+			return
+		}
+		time.Sleep(1200 * time.Millisecond)
+		w.Write([]byte(getResponseSuccess))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+		Timeout:  1,
+	})
+	require.NoError(t, err)
+
+	// without a deadline the client Timeout applies
+	_, err = client.Stream(context.Background(), http.MethodGet, findLeadPath, nil)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.Stream(ctx, http.MethodGet, findLeadPath, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestStreamRetryBody(t *testing.T) {
+	t.Run("seekable", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			Times(2).
+			Reply(http.StatusOK).
+			JSON(fmt.Sprintf(authResponseSuccess, token))
+		gock.New(testHost).
+			Post("/rest/v1/leads.json").
+			Reply(http.StatusOK).
+			JSON(invalidTokenResponse)
+		gock.New(testHost).
+			Post("/rest/v1/leads.json").
+			BodyString(`{"input":[]}`).
+			Reply(http.StatusOK).
+			JSON(getResponseSuccess)
+
+		client, err := NewClient(ClientConfig{
+			ID:       clientID,
+			Secret:   clientSecret,
+			Endpoint: testHost,
+		})
+		require.NoError(t, err)
+
+		// hide the concrete type so the body is only known to be seekable
+		body := struct{ io.ReadSeeker }{strings.NewReader(`{"input":[]}`)}
+		resp, err := client.Stream(context.Background(), http.MethodPost, "/rest/v1/leads.json", body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("not seekable", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			Times(2).
+			Reply(http.StatusOK).
+			JSON(fmt.Sprintf(authResponseSuccess, token))
+		gock.New(testHost).
+			Post("/rest/v1/leads.json").
+			Reply(http.StatusOK).
+			JSON(invalidTokenResponse)
+
+		client, err := NewClient(ClientConfig{
+			ID:       clientID,
+			Secret:   clientSecret,
+			Endpoint: testHost,
+		})
+		require.NoError(t, err)
+
+		body := io.MultiReader(strings.NewReader(`{"input":[]}`))
+		_, err = client.Stream(context.Background(), http.MethodPost, "/rest/v1/leads.json", body)
+		assert.True(t, errors.Is(err, ErrAccessTokenInvalid))
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestWithHeader(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		MatchHeader("X-Tenant", "acme").
+		MatchHeader("Authorization", "Bearer "+token).
+		Reply(http.StatusOK).
+		JSON(getResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchHeader("X-Trace-Id", "abc123").
+		MatchHeader("Content-Type", "application/json").
+		Reply(http.StatusOK).
+		JSON(getResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Get("/rest/v1/leads.json", WithHeader("X-Tenant", "acme"))
+	require.NoError(t, err)
+	_, err = client.Post("/rest/v1/leads.json", []byte(`{}`), WithHeader("X-Trace-Id", "abc123"))
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDisableAutoRefresh(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseExpiringSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(invalidTokenResponse)
+
+	client, err := NewClient(ClientConfig{
+		ID:                 clientID,
+		Secret:             clientSecret,
+		Endpoint:           testHost,
+		DisableAutoRefresh: true,
+	})
+	require.NoError(t, err)
+
+	// an invalid token is reported rather than refreshed
+	_, err = client.Get("/rest/v1/leads.json")
+	assert.True(t, errors.Is(err, ErrAccessTokenInvalid))
+
+	// as is an expired one, without making the request
+	time.Sleep(1100 * time.Millisecond)
+	_, err = client.Get("/rest/v1/leads.json")
+	assert.True(t, errors.Is(err, ErrAccessTokenExpired))
+	assert.True(t, gock.IsDone())
+}
+
+func TestTransportErrorOperation(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	failure := errors.New("connection reset")
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		ReplyError(failure)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = NewLeadAPI(client).DescribeFields(context.Background())
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), describeLead2+": "), err.Error())
+	assert.True(t, errors.Is(err, failure))
+	assert.True(t, gock.IsDone())
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	defer gock.Off()
+
+	body := `{"requestId":"1000","success":true,"result":[]}`
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Times(2).
+		Reply(http.StatusOK).
+		JSON(body)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		JSON(body)
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		MaxResponseBytes: int64(len(body)),
+	})
+	require.NoError(t, err)
+
+	// a body of exactly MaxResponseBytes is read
+	_, err = client.Get("/rest/v1/leads.json")
+	require.NoError(t, err)
+
+	client.maxResponseBytes = int64(len(body)) - 1
+	_, err = client.Get("/rest/v1/leads.json")
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), err)
+	status, ok := HTTPStatus(err)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, err = NewLeadAPI(client).DescribeFields(context.Background())
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGatewayRetry(t *testing.T) {
+	defer gock.Off()
+	defer func(wait time.Duration) { gatewayBackoff = wait }(gatewayBackoff)
+	gatewayBackoff = time.Millisecond
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusServiceUnavailable).
+		BodyString("<html>Service Unavailable</html>")
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1000","success":true,"result":[]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		Reply(http.StatusBadGateway).
+		BodyString("<html>Bad Gateway</html>")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		Reply(http.StatusGatewayTimeout).
+		BodyString("<html>Gateway Timeout</html>")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "email", r.PostForm.Get("filterType"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	response, err := client.Get("/rest/v1/leads.json")
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+
+	leads, _, err := NewLeadAPI(client).Filter(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"nathan@polytomic.com"}),
+	)
+	require.NoError(t, err)
+	assert.NotEmpty(t, leads)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGatewayRetry_writes(t *testing.T) {
+	defer func(wait time.Duration) { gatewayBackoff = wait }(gatewayBackoff)
+	gatewayBackoff = time.Millisecond
+
+	for _, retryWrites := range []bool{false, true} {
+		t.Run(fmt.Sprintf("RetryWrites=%t", retryWrites), func(t *testing.T) {
+			defer gock.Off()
+
+			gock.New(testHost).
+				Get("/identity/oauth/token").
+				Reply(http.StatusOK).
+				JSON(fmt.Sprintf(authResponseSuccess, token))
+			gock.New(testHost).
+				Post("/rest/v1/leads.json").
+				Reply(http.StatusBadGateway).
+				BodyString("<html>Bad Gateway</html>")
+			gock.New(testHost).
+				Post("/rest/v1/leads.json").
+				BodyString(`{"input":[{"email":"tester@example.com"}]}`).
+				Reply(http.StatusOK).
+				JSON(`{"requestId":"1001","success":true,"result":[{"id":1,"status":"created"}]}`)
+
+			client, err := NewClient(ClientConfig{
+				ID:          clientID,
+				Secret:      clientSecret,
+				Endpoint:    testHost,
+				RetryWrites: retryWrites,
+			})
+			require.NoError(t, err)
+
+			results, err := NewLeadAPI(client).CreateOrUpdate(
+				context.Background(),
+				[]map[string]interface{}{{"email": "tester@example.com"}},
+			)
+			if !retryWrites {
+				assert.Error(t, err)
+				assert.False(t, gock.IsDone())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, 1, results[0].ID)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func TestGatewayRetry_disabled(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusServiceUnavailable).
+		BodyString("<html>Service Unavailable</html>")
+
+	client, err := NewClient(ClientConfig{
+		ID:             clientID,
+		Secret:         clientSecret,
+		Endpoint:       testHost,
+		GatewayRetries: -1,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Get("/rest/v1/leads.json")
+	assert.Error(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestStreamRedirect(t *testing.T) {
+	download := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("email\ntester@example.com\n"))
+	}))
+	defer download.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(authResponseSuccess, token)))
+			return
+		}
+		assert.Equal(t, "Bearer "+token, r.Header.Get("Authorization"))
+		http.Redirect(w, r, download.URL+"/signed/file.csv", http.StatusSeeOther)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(t, err)
+
+	resp, err := client.Stream(context.Background(), http.MethodGet, "/rest/v1/files/1/content.json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "email\ntester@example.com\n", string(body))
+}
+
+func TestIdleConnTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(authResponseSuccess, token)))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:              clientID,
+		Secret:          clientSecret,
+		Endpoint:        ts.URL,
+		IdleConnTimeout: 10 * time.Second,
+		KeepAlive:       -1,
+	})
+	require.NoError(t, err)
+
+	transport, ok := client.restRoundTripper.delegate.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Second, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.NotSame(t, http.DefaultTransport, transport)
+
+	custom := &http.Transport{}
+	config := ClientConfig{IdleConnTimeout: time.Second}
+	assert.Equal(t, custom, config.transport(custom))
+	assert.Nil(t, ClientConfig{}.transport(nil))
+}
+
+func TestTransport(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, "refreshed"))
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchHeader("Authorization", "Bearer "+token).
+		Reply(http.StatusOK).
+		JSON(invalidTokenResponse)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchHeader("Authorization", "Bearer refreshed").
+		BodyString(`{"input":[]}`).
+		Reply(http.StatusOK).
+		JSON(getResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, testHost+"/rest/v1/leads.json", strings.NewReader(`{"input":[]}`))
+	require.NoError(t, err)
+	httpClient := &http.Client{Transport: client.Transport()}
+	resp, err := httpClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var response Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.True(t, response.Success)
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.True(t, gock.IsDone())
+}
+
+func TestRefreshTokenError_preserved(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(fmt.Sprintf(authResponseSuccess, token))
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(invalidTokenResponse)
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusUnauthorized).
+		JSON(authResponseError)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Get("/rest/v1/leads.json")
+	var authErr AuthError
+	require.True(t, errors.As(err, &authErr), err)
+	assert.Equal(t, "invalid_client", authErr.Code)
+	assert.Equal(t, "refreshing invalid or expired token: authentication error: 401 invalid_client: Bad client credentials", err.Error())
+	assert.True(t, gock.IsDone())
+}
@@ -1,10 +1,12 @@
 package marketo
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -39,16 +41,25 @@ type ObjectRelation struct {
 }
 
 type ObjectField struct {
-	DataType    string `json:"dataType"`
-	DisplayName string `json:"displayName"`
-	Length      int    `json:"length"`
-	Name        string `json:"name"`
-	Updateable  bool   `json:"updateable"`
-	CRMManaged  bool   `json:"crmManaged"`
+	DataType    DataType `json:"dataType"`
+	DisplayName string   `json:"displayName"`
+	Length      int      `json:"length"`
+	Name        string   `json:"name"`
+	Updateable  bool     `json:"updateable"`
+	CRMManaged  bool     `json:"crmManaged"`
+	// Values contains the allowed values of a picklist field, if Marketo
+	// returns them
+	Values []string `json:"values,omitempty"`
 
 	Searchable bool `json:"searchable,omitEmpty"`
 }
 
+// Writable returns true if the field may be written: it is updateable and,
+// for instances synced with a CRM, not managed by the CRM.
+func (f ObjectField) Writable() bool {
+	return f.Updateable && !f.CRMManaged
+}
+
 type CustomObjectMetadata struct {
 	IDField          string           `json:"idField"`
 	APIName          string           `json:"name"`
@@ -65,39 +76,171 @@ type CustomObjectMetadata struct {
 	Version          ObjectVersion    `json:"version"`
 }
 
+// copy returns a copy of m which shares no slices with it
+func (m CustomObjectMetadata) copy() CustomObjectMetadata {
+	fields := make([]ObjectField, len(m.Fields))
+	for i, f := range m.Fields {
+		f.Values = append([]string(nil), f.Values...)
+		fields[i] = f
+	}
+	m.Fields = fields
+	if m.SearchableFields != nil {
+		m.SearchableFields = m.SearchableFieldSets()
+	}
+	m.DedupeFields = append([]string(nil), m.DedupeFields...)
+	m.Relationships = append([]ObjectRelation(nil), m.Relationships...)
+	return m
+}
+
+// SearchableFieldSets returns the sets of fields which may be used to filter
+// the custom object; fields in a set with more than one member form a
+// composite key and must be searched together.
+func (m CustomObjectMetadata) SearchableFieldSets() [][]string {
+	sets := make([][]string, len(m.SearchableFields))
+	for i, set := range m.SearchableFields {
+		sets[i] = append([]string{}, set...)
+	}
+	return sets
+}
+
+// FieldNames returns the names of the object's fields
+func (m CustomObjectMetadata) FieldNames() []string {
+	return m.fieldNames(func(ObjectField) bool { return true })
+}
+
+// UpdateableFieldNames returns the names of the object's fields which may be
+// updated
+func (m CustomObjectMetadata) UpdateableFieldNames() []string {
+	return m.fieldNames(func(f ObjectField) bool { return f.Updateable })
+}
+
+// WritableFields returns the object's fields which may be written
+func (m CustomObjectMetadata) WritableFields() []ObjectField {
+	writable := []ObjectField{}
+	for _, f := range m.Fields {
+		if f.Writable() {
+			writable = append(writable, f)
+		}
+	}
+	return writable
+}
+
+// WritableFieldNames returns the names of the object's fields which may be
+// written
+func (m CustomObjectMetadata) WritableFieldNames() []string {
+	return m.fieldNames(ObjectField.Writable)
+}
+
+// SearchableFieldNames returns the names of the object's fields which are a
+// member of one of its SearchableFieldSets
+func (m CustomObjectMetadata) SearchableFieldNames() []string {
+	searchable := map[string]bool{}
+	for _, set := range m.SearchableFields {
+		for _, f := range set {
+			searchable[f] = true
+		}
+	}
+	return m.fieldNames(func(f ObjectField) bool { return searchable[f.Name] })
+}
+
+// fieldNames returns the names of the object's fields for which include
+// returns true
+func (m CustomObjectMetadata) fieldNames(include func(ObjectField) bool) []string {
+	names := []string{}
+	for _, f := range m.Fields {
+		if include(f) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// validateFilterField returns an error if field may not be used on its own to
+// filter the custom object.
+func (m CustomObjectMetadata) validateFilterField(field string) error {
+	var composite []string
+	valid := []string{}
+	for _, set := range m.SearchableFields {
+		if len(set) == 1 {
+			if set[0] == field {
+				return nil
+			}
+			valid = append(valid, set[0])
+			continue
+		}
+		for _, f := range set {
+			if f == field {
+				composite = set
+			}
+		}
+	}
+
+	if composite != nil {
+		return fmt.Errorf(
+			"invalid filter field %q: must be searched together with %s",
+			field, strings.Join(composite, ", "),
+		)
+	}
+	return fmt.Errorf(
+		"invalid filter field %q: must be one of %s",
+		field, strings.Join(valid, ", "),
+	)
+}
+
 // CustomObjectResult contains a single record returned when filtering custom
 // objects.
 type CustomObjectResult struct {
-	MarketoGUID string                 `json:"marketoGUID"`
-	Sequence    int                    `json:"seq"`
+	MarketoGUID string                 `json:"marketoGUID" mapstructure:"marketoGUID"`
+	Sequence    int                    `json:"seq" mapstructure:"seq"`
 	Fields      map[string]interface{} `json:"-" mapstructure:",remain"`
 }
 
 const (
 	describeCustomObject = "describe custom object"
 	listCustomObjects    = "list custom objects"
+	filterCustomObjects  = "filter custom objects"
+	syncCustomObjects    = "sync custom objects"
+
+	customObjectDescribeKind = "customobject"
 )
 
+// CustomObjectService is the set of custom object operations provided by
+// CustomObjects; code which depends on it may substitute a fake in tests.
+type CustomObjectService interface {
+	List(ctx context.Context) ([]CustomObjectMetadata, error)
+	Describe(ctx context.Context, name string) (*CustomObjectMetadata, error)
+	ClearDescribeCache()
+	DescribeCacheStats() DescribeCacheStats
+	Filter(ctx context.Context, name string, opts ...QueryOption) ([]CustomObjectResult, string, error)
+	FilterByIDField(ctx context.Context, name string, values []string, opts ...QueryOption) ([]CustomObjectResult, string, error)
+	FilterRaw(ctx context.Context, name string, opts ...QueryOption) (*Response, error)
+	CreateOrUpdate(ctx context.Context, name string, records []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error)
+	SyncStream(ctx context.Context, name string, records RecordReader, opts ...SyncOption) ([]RecordResult, error)
+}
+
+var _ CustomObjectService = (*CustomObjects)(nil)
+
 // CustomObjects provides access to the Marketo custom objects API
 type CustomObjects struct {
 	*Client
 }
 
-// NewCustomObjectsAPI returns a new instance of the
+// NewCustomObjectsAPI returns a new instance of the custom objects API,
+// configured with the provided Client.
 func NewCustomObjectsAPI(c *Client) *CustomObjects {
-	return &CustomObjects{c}
+	return &CustomObjects{Client: c}
 }
 
 // List returns the custom objects supported by the Marketo instance
 func (c *CustomObjects) List(ctx context.Context) ([]CustomObjectMetadata, error) {
-	request, err := http.NewRequest(
-		http.MethodGet, c.url("rest", "v1", "customobjects.json"), nil,
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, c.restURL("customobjects.json"), nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Client.doRequest(request)
+	resp, err := c.Client.doRequest(listCustomObjects, request)
 	if err != nil {
 		return nil, err
 	}
@@ -120,16 +263,31 @@ func (c *CustomObjects) List(ctx context.Context) ([]CustomObjectMetadata, error
 
 }
 
-// Describe returns the description for the provided custom object
+// customObjectDescribeKey identifies the description of the custom object
+// name in the Client's describe cache
+func customObjectDescribeKey(name string) describeKey {
+	return describeKey{kind: customObjectDescribeKind, name: name}
+}
+
+// Describe returns the description for the provided custom object. If the
+// Client is configured with a DescribeCacheTTL, the cached description is
+// returned until it expires.
 func (c *CustomObjects) Describe(ctx context.Context, name string) (*CustomObjectMetadata, error) {
-	request, err := http.NewRequest(
-		http.MethodGet, c.url("rest", "v1", "customobjects", name, "describe.json"), nil,
+	// the cache is shared by every API using the Client, so callers are
+	// given copies which they may modify
+	if object, ok := c.describe.get(customObjectDescribeKey(name)); ok {
+		metadata := object.(CustomObjectMetadata).copy()
+		return &metadata, nil
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, c.restURL("customobjects", name, "describe.json"), nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Client.doRequest(request)
+	resp, err := c.Client.doRequest(describeCustomObject, request)
 	if err != nil {
 		return nil, err
 	}
@@ -164,59 +322,347 @@ func (c *CustomObjects) Describe(ctx context.Context, name string) (*CustomObjec
 		object[0].Fields[i] = field
 	}
 
+	if err == nil {
+		c.describe.set(customObjectDescribeKey(name), object[0].copy())
+	}
 	return &object[0], err
 }
 
+// ClearDescribeCache discards any cached describe results, forcing the next
+// call to Describe to fetch the description from Marketo.
+func (c *CustomObjects) ClearDescribeCache() {
+	c.describe.clear(customObjectDescribeKind)
+}
+
+// DescribeCacheStats returns the number of Describe calls served from the
+// cache and fetched from Marketo.
+func (c *CustomObjects) DescribeCacheStats() DescribeCacheStats {
+	return c.describe.describeStats(customObjectDescribeKind)
+}
+
 // Filter queries Marketo for custom objects that match the provided filters.
+// If the Client is configured with ValidateFilters, the filter field is
+// validated against the object's searchable fields before making the request.
+// If the page token has expired the returned error matches
+// ErrStalePagingToken.
 func (c *CustomObjects) Filter(ctx context.Context, name string, opts ...QueryOption) ([]CustomObjectResult, string, error) {
+	response, err := c.FilterRaw(ctx, name, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(response.Errors) > 0 {
+		return nil, "", pagingTokenError(responseError(filterCustomObjects, http.StatusOK, response))
+	}
+
+	// decode numbers as json.Number to preserve the precision of large
+	// integer identifiers
+	raw := []map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(response.Result))
+	dec.UseNumber()
+	err = dec.Decode(&raw)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]CustomObjectResult, len(raw))
+	for i, l := range raw {
+		err = mapstructure.Decode(l, &results[i])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return results, response.NextPageToken, nil
+}
+
+// FilterByIDField queries Marketo for the custom objects whose idField
+// matches one of values. The idField is read from the object's description,
+// which is cached if the Client is configured with a DescribeCacheTTL; opts may
+// be used to select fields or a page.
+func (c *CustomObjects) FilterByIDField(ctx context.Context, name string, values []string, opts ...QueryOption) ([]CustomObjectResult, string, error) {
+	metadata, err := c.Describe(ctx, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if metadata.IDField == "" {
+		return nil, "", fmt.Errorf("no idField for custom object %q", name)
+	}
+	return c.Filter(ctx, name, append(
+		opts, FilterField(metadata.IDField), FilterValues(values),
+	)...)
+}
+
+// FilterRaw queries Marketo for custom objects that match the provided
+// filters, returning the complete response envelope with the Result left
+// undecoded. If no FilterField is provided, the object's IDField is used.
+func (c *CustomObjects) FilterRaw(ctx context.Context, name string, opts ...QueryOption) (*Response, error) {
 	q := &Query{}
 	for _, opt := range opts {
 		opt(q)
 	}
+	ctx, cancel := withTimeout(ctx, q.Timeout)
+	defer cancel()
+
+	// without an explicit filter field, filter using the object's idField
+	if c.validateFilters || q.FilterField == "" || q.AllFields {
+		metadata, err := c.Describe(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if q.AllFields {
+			q.Fields = metadata.FieldNames()
+		}
+		if q.FilterField == "" {
+			q.FilterField = metadata.IDField
+		}
+		if c.validateFilters {
+			if err := metadata.validateFilterField(q.FilterField); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range metadata.Fields {
+			if f.Name == q.FilterField && f.DataType.GoType() == intType {
+				if err := validateIntegerValues(q.FilterField, q.FilterValues); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
 
 	query, err := q.Values()
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	request, err := http.NewRequest(
-		http.MethodPost,
-		c.url("rest", "v1", "customobjects", fmt.Sprintf("%s.json?_method=GET", name)),
-		strings.NewReader(query.Encode()),
+	request, err := filterRequest(
+		ctx, c.restURL("customobjects", fmt.Sprintf("%s.json", name)), query, q.PreferGET,
 	)
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	resp, err := c.doRequest(request)
+	resp, err := c.doRequest(filterCustomObjects, request)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", handleError(filterLeads, resp)
+		return nil, handleError(filterCustomObjects, resp)
 	}
 
 	response := &Response{}
 	reader := json.NewDecoder(resp.Body)
 	err = reader.Decode(response)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	raw := []map[string]interface{}{}
-	err = json.Unmarshal(response.Result, &raw)
+	return response, nil
+}
+
+// CreateOrUpdate syncs up to MaximumSyncBatchSize records of the named custom
+// object to Marketo, returning the result for each; the MarketoGUID of each
+// result identifies the record. With the DryRun option the records are
+// validated against the object's description but not sent, and no results
+// are returned. The PartitionName option is only supported for Leads.
+func (c *CustomObjects) CreateOrUpdate(ctx context.Context, name string, records []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error) {
+	sr := &SyncRequest{Input: records}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	ctx, cancel := withTimeout(ctx, sr.Timeout)
+	defer cancel()
+	if len(sr.Input) > MaximumSyncBatchSize {
+		return nil, errors.New("too many records")
+	}
+	if sr.PartitionName != "" {
+		return nil, errors.New("custom object sync does not support the PartitionName option")
+	}
+	if sr.DryRun {
+		metadata, err := c.Describe(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for i, record := range sr.Input {
+			if err := validateObjectRecord(i, record, sr, metadata); err != nil {
+				return nil, err
+			}
+		}
+		if sr.dryRun != nil {
+			*sr.dryRun = *sr
+			sr.dryRun.dryRun = nil
+		}
+		return nil, nil
+	}
+
+	body, err := json.Marshal(sr)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
+	return c.sync(ctx, name, bytes.NewReader(body))
+}
 
-	results := make([]CustomObjectResult, len(raw))
-	for i, l := range raw {
-		err = mapstructure.Decode(l, &results[i])
+// SyncStream syncs the records of the named custom object read from records
+// to Marketo, returning the result for each. Records are sent in batches of
+// MaximumSyncBatchSize, and each batch is encoded as it is sent, so neither
+// the records nor the request body need be held in memory; this suits objects
+// with many fields. If a batch fails, the results of the preceding batches
+// are returned along with the error.
+//
+// As a streamed batch cannot be resent, a batch rejected because the access
+// token expired while it was in flight fails rather than being retried, and
+// if the Client is configured with CompressRequests each compressed batch is
+// buffered before it is sent. The options accepted by CreateOrUpdate are
+// supported; with DryRun every record is read and validated, but none are
+// sent and the Input of the dry run copy is left empty.
+func (c *CustomObjects) SyncStream(ctx context.Context, name string, records RecordReader, opts ...SyncOption) ([]RecordResult, error) {
+	sr := &SyncRequest{}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	ctx, cancel := withTimeout(ctx, sr.Timeout)
+	defer cancel()
+	if sr.PartitionName != "" {
+		return nil, errors.New("custom object sync does not support the PartitionName option")
+	}
+	if sr.DryRun {
+		return nil, c.dryRunStream(ctx, name, sr, records)
+	}
+
+	results := []RecordResult{}
+	for {
+		first, err := records.Next()
+		if err == io.EOF {
+			return results, nil
+		}
 		if err != nil {
-			return nil, "", err
+			return results, err
+		}
+
+		pr, pw := io.Pipe()
+		type written struct {
+			exhausted bool
+			err       error
+		}
+		done := make(chan written, 1)
+		go func() {
+			exhausted, err := writeSyncBatch(pw, *sr, first, records)
+			pw.CloseWithError(err)
+			done <- written{exhausted, err}
+		}()
+
+		batch, err := c.sync(ctx, name, pr)
+		// unblock the writer if the request ended before reading the body
+		pr.Close()
+		w := <-done
+		if w.err != nil && w.err != io.ErrClosedPipe {
+			return results, w.err
+		}
+		if err != nil {
+			return results, err
+		}
+		if w.err != nil {
+			return results, w.err
+		}
+		results = append(results, batch...)
+		if w.exhausted {
+			return results, nil
 		}
 	}
+}
 
-	return results, response.NextPageToken, nil
+// validateObjectRecord returns an error if record i of sr contains fields
+// the custom object described by metadata does not have, or is missing the
+// fields used to deduplicate it.
+func validateObjectRecord(i int, record map[string]interface{}, sr *SyncRequest, metadata *CustomObjectMetadata) error {
+	known := make(map[string]bool, len(metadata.Fields))
+	for _, f := range metadata.Fields {
+		known[f.Name] = true
+	}
+	for name := range record {
+		if !known[name] {
+			return fmt.Errorf("record %d: unknown field %q", i, name)
+		}
+	}
+
+	if sr.Action == ActionCreateOnly || sr.Action == ActionCreateDuplicate {
+		return nil
+	}
+	dedupe := metadata.DedupeFields
+	if sr.DedupeBy == DedupeByIDField {
+		dedupe = []string{metadata.IDField}
+	}
+	for _, f := range dedupe {
+		if _, ok := record[f]; !ok {
+			return fmt.Errorf("record %d: missing dedupe field %q", i, f)
+		}
+	}
+	return nil
+}
+
+// dryRunStream validates each of the records read from records against the
+// description of the named custom object, without sending them. The dry run
+// copy of sr has no Input, as the records are not retained.
+func (c *CustomObjects) dryRunStream(ctx context.Context, name string, sr *SyncRequest, records RecordReader) error {
+	metadata, err := c.Describe(ctx, name)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		record, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := validateObjectRecord(i, record, sr, metadata); err != nil {
+			return err
+		}
+	}
+	if sr.dryRun != nil {
+		*sr.dryRun = *sr
+		sr.dryRun.dryRun = nil
+	}
+	return nil
+}
+
+// sync posts body, a sync request, for the named custom object and returns
+// the result for each record.
+func (c *CustomObjects) sync(ctx context.Context, name string, body io.Reader) ([]RecordResult, error) {
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.restURL("customobjects", fmt.Sprintf("%s.json", name)),
+		body,
+	)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("Content-Type", "application/json")
+
+	resp, err := c.doRequest(syncCustomObjects, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(syncCustomObjects, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, responseError(syncCustomObjects, resp.StatusCode, response)
+	}
+
+	results := []RecordResult{}
+	err = json.Unmarshal(response.Result, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
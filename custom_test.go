@@ -2,8 +2,17 @@ package marketo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,6 +80,19 @@ func TestCustomObjectDescribe(t *testing.T) {
 
 		assert.Len(t, obj.Fields, 6)
 
+		t.Run("field names", func(t *testing.T) {
+			assert.Equal(t, []string{"createdAt", "marketoGUID", "updatedAt", "email", "firstName", "lastName"}, obj.FieldNames())
+			assert.Equal(t, []string{"email", "firstName", "lastName"}, obj.UpdateableFieldNames())
+			assert.Equal(t, []string{"marketoGUID", "email"}, obj.SearchableFieldNames())
+			assert.Equal(t, []string{"email", "firstName", "lastName"}, obj.WritableFieldNames())
+
+			crm := CustomObjectMetadata{Fields: []ObjectField{
+				{Name: "email", Updateable: true},
+				{Name: "accountId", Updateable: true, CRMManaged: true},
+			}}
+			assert.Equal(t, crm.Fields[:1], crm.WritableFields())
+		})
+
 		t.Run("adds searchable tag to fields", func(t *testing.T) {
 			var passed bool
 			for _, f := range obj.Fields {
@@ -111,6 +133,36 @@ func TestCustomObjectDescribe(t *testing.T) {
 
 		assert.True(t, gock.IsDone())
 	})
+
+	t.Run("cached", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			Reply(http.StatusOK).
+			JSON(authResponseSuccess)
+		gock.New(testHost).
+			Get("/rest/v1/customobjects/testObject_c/describe.json").
+			Reply(http.StatusOK).
+			File("test-fixtures/testObject_c-describe.json")
+
+		client, err := NewClient(ClientConfig{
+			ID:               clientID,
+			Secret:           clientSecret,
+			Endpoint:         "https://marketo.testing",
+			DescribeCacheTTL: time.Hour,
+		})
+		require.NoError(t, err)
+
+		api := NewCustomObjectsAPI(client)
+		first, err := api.Describe(context.Background(), "testObject_c")
+		require.NoError(t, err)
+		second, err := api.Describe(context.Background(), "testObject_c")
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.True(t, gock.IsDone())
+	})
 }
 
 func TestFitlerCustomObjects(t *testing.T) {
@@ -125,6 +177,7 @@ func TestFitlerCustomObjects(t *testing.T) {
 		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
 			require.NoError(t, r.ParseForm())
 			assert.Equal(t, "email", r.PostForm.Get("filterType"))
+			assert.Equal(t, "email,name", r.PostForm.Get("fields"))
 			return true, nil
 		}).
 		Reply(http.StatusOK).
@@ -144,6 +197,7 @@ func TestFitlerCustomObjects(t *testing.T) {
 		"testObject_c",
 		FilterField("email"),
 		FilterValues([]string{"nathan@polytomic.com", "ghalib@polytomic.com"}),
+		GetFields("email", "name"),
 	)
 	require.NoError(t, err)
 
@@ -151,3 +205,567 @@ func TestFitlerCustomObjects(t *testing.T) {
 	assert.Equal(t, "nathan@polytomic.com", leads[0].Fields["email"])
 	assert.True(t, gock.IsDone())
 }
+
+func TestFilterCustomObjects_defaultsToIDField(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+	gock.New(testHost).
+		Post("/rest/v1/customobjects/testObject_c.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "marketoGUID", r.PostForm.Get("filterType"))
+			assert.Equal(t, "dff23271-f996-47d7-984f-f2676861b5fa", r.PostForm.Get("filterValues"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterCustomObject.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	api := NewCustomObjectsAPI(client)
+	_, _, err = api.Filter(
+		context.Background(),
+		"testObject_c",
+		FilterValues([]string{"dff23271-f996-47d7-984f-f2676861b5fa"}),
+	)
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterCustomObjectsByIDField(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+	gock.New(testHost).
+		Post("/rest/v1/customobjects/testObject_c.json").
+		Times(2).
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "marketoGUID", r.PostForm.Get("filterType"))
+			assert.Equal(t, "dff23271-f996-47d7-984f-f2676861b5fa", r.PostForm.Get("filterValues"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterCustomObject.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	api := NewCustomObjectsAPI(client)
+	for i := 0; i < 2; i++ {
+		results, _, err := api.FilterByIDField(
+			context.Background(),
+			"testObject_c",
+			[]string{"dff23271-f996-47d7-984f-f2676861b5fa"},
+		)
+		require.NoError(t, err)
+		assert.NotEmpty(t, results)
+	}
+	assert.Equal(t, DescribeCacheStats{Hits: 1, Misses: 1}, api.DescribeCacheStats())
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterCustomObjects_largeIDs(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/customobjects/testObject_c.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"seq":0,"marketoGUID":"abc","recordId":1234567890123456789}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+	})
+	require.NoError(t, err)
+
+	api := NewCustomObjectsAPI(client)
+	results, _, err := api.Filter(
+		context.Background(),
+		"testObject_c",
+		FilterField("recordId"),
+		FilterValues([]string{"1234567890123456789"}),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, json.Number("1234567890123456789"), results[0].Fields["recordId"])
+
+	encoded, err := json.Marshal(results[0].Fields)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"recordId":1234567890123456789}`, string(encoded))
+	assert.True(t, gock.IsDone())
+}
+
+func TestSearchableFieldSets(t *testing.T) {
+	metadata := CustomObjectMetadata{
+		SearchableFields: [][]string{
+			{"marketoGUID"},
+			{"orderNumber", "lineNumber"},
+		},
+	}
+
+	sets := metadata.SearchableFieldSets()
+	assert.Equal(t, metadata.SearchableFields, sets)
+	sets[0][0] = "changed"
+	assert.Equal(t, "marketoGUID", metadata.SearchableFields[0][0])
+
+	assert.NoError(t, metadata.validateFilterField("marketoGUID"))
+	err := metadata.validateFilterField("orderNumber")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be searched together with orderNumber, lineNumber")
+	err = metadata.validateFilterField("email")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be one of marketoGUID")
+}
+
+func TestFilterCustomObjects_validatesField(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:              clientID,
+		Secret:          clientSecret,
+		Endpoint:        "https://marketo.testing",
+		ValidateFilters: true,
+	})
+	require.NoError(t, err)
+
+	api := NewCustomObjectsAPI(client)
+	_, _, err = api.Filter(
+		context.Background(),
+		"testObject_c",
+		FilterField("name"),
+		FilterValues([]string{"test"}),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid filter field "name"`)
+	assert.True(t, gock.IsDone())
+}
+
+func TestListCustomObjects_restVersion(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v2/customobjects.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/customobjects.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:          clientID,
+		Secret:      clientSecret,
+		Endpoint:    "https://marketo.testing",
+		RESTVersion: "v2",
+	})
+	require.NoError(t, err)
+
+	objects, err := NewCustomObjectsAPI(client).List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreateOrUpdateCustomObjects(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/customobjects/testObject_c.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"action":   "createOrUpdate",
+			"dedupeBy": "dedupeFields",
+			"input": []map[string]interface{}{
+				{"email": "nathan@polytomic.com", "firstName": "Nathan"},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"seq":0,"marketoGUID":"dff23271-f996-47d7-984f-f2676861b5fa","status":"created"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	results, err := NewCustomObjectsAPI(client).CreateOrUpdate(
+		context.Background(), "testObject_c",
+		[]map[string]interface{}{{"email": "nathan@polytomic.com", "firstName": "Nathan"}},
+		Action(ActionCreateOrUpdate), DedupeBy(DedupeByDedupeFields),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, RecordResult{
+		MarketoGUID: "dff23271-f996-47d7-984f-f2676861b5fa",
+		Status:      RecordCreated,
+	}, results[0])
+	assert.True(t, gock.IsDone())
+}
+
+// syncServer returns a server which accepts custom object syncs, responding
+// with a result for each record, and counts the batches and records received
+func syncServer(tb testing.TB, batches, records *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/identity/oauth/token" {
+			fmt.Fprintf(w, authResponseSuccess, token)
+			return
+		}
+
+		payload := struct {
+			DedupeBy string            `json:"dedupeBy"`
+			Input    []json.RawMessage `json:"input"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			tb.Errorf("decoding sync: %v", err)
+		}
+		if payload.DedupeBy != DedupeByIDField {
+			tb.Errorf("expected dedupeBy %q, got %q", DedupeByIDField, payload.DedupeBy)
+		}
+		atomic.AddInt32(batches, 1)
+		results := make([]RecordResult, len(payload.Input))
+		for i := range results {
+			results[i] = RecordResult{
+				MarketoGUID: strconv.Itoa(int(atomic.AddInt32(records, 1))),
+				Status:      RecordCreated,
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"requestId": "1",
+			"success":   true,
+			"result":    results,
+		})
+	}))
+}
+
+// wideRecords returns a RecordReader which generates n records with the given
+// number of fields
+func wideRecords(n, fields int) RecordReader {
+	i := 0
+	return RecordReaderFunc(func() (map[string]interface{}, error) {
+		if i == n {
+			return nil, io.EOF
+		}
+		record := make(map[string]interface{}, fields+1)
+		record["externalID"] = strconv.Itoa(i)
+		for f := 0; f < fields; f++ {
+			record[fmt.Sprintf("field%d_c", f)] = fmt.Sprintf("value %d of record %d", f, i)
+		}
+		i++
+		return record, nil
+	})
+}
+
+func TestSyncStream(t *testing.T) {
+	var batches, records int32
+	ts := syncServer(t, &batches, &records)
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(t, err)
+	api := NewCustomObjectsAPI(client)
+
+	results, err := api.SyncStream(context.Background(), "wide_c", wideRecords(10000, 20), DedupeBy(DedupeByIDField))
+	require.NoError(t, err)
+	assert.Len(t, results, 10000)
+	assert.Equal(t, "10000", results[9999].MarketoGUID)
+	assert.EqualValues(t, 34, batches)
+
+	// a full final batch does not result in an empty request
+	atomic.StoreInt32(&batches, 0)
+	results, err = api.SyncStream(context.Background(), "wide_c", wideRecords(MaximumSyncBatchSize, 1), DedupeBy(DedupeByIDField))
+	require.NoError(t, err)
+	assert.Len(t, results, MaximumSyncBatchSize)
+	assert.EqualValues(t, 1, batches)
+
+	results, err = api.SyncStream(context.Background(), "wide_c", SliceRecordReader(nil))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestSyncStream_readerError(t *testing.T) {
+	var batches, records int32
+	ts := syncServer(t, &batches, &records)
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(t, err)
+
+	source := wideRecords(MaximumSyncBatchSize+10, 1)
+	read := 0
+	failing := RecordReaderFunc(func() (map[string]interface{}, error) {
+		if read++; read > MaximumSyncBatchSize+5 {
+			return nil, errors.New("source failed")
+		}
+		return source.Next()
+	})
+
+	results, err := NewCustomObjectsAPI(client).SyncStream(context.Background(), "wide_c", failing, DedupeBy(DedupeByIDField))
+	assert.EqualError(t, err, "source failed")
+	assert.Len(t, results, MaximumSyncBatchSize)
+	assert.EqualValues(t, 1, batches)
+}
+
+// peakHeap returns the largest heap in use, sampled while f runs
+func peakHeap(f func()) uint64 {
+	runtime.GC()
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		var max uint64
+		stats := runtime.MemStats{}
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > max {
+				max = stats.HeapInuse
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	return <-peak
+}
+
+func BenchmarkSyncStream(b *testing.B) {
+	const n, fields = 10000, 200
+
+	var batches, records int32
+	ts := syncServer(b, &batches, &records)
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(b, err)
+	api := NewCustomObjectsAPI(client)
+
+	b.Run("slice", func(b *testing.B) {
+		b.ReportAllocs()
+		var peak uint64
+		for i := 0; i < b.N; i++ {
+			peak = peakHeap(func() {
+				input := []map[string]interface{}{}
+				source := wideRecords(n, fields)
+				for record, err := source.Next(); err == nil; record, err = source.Next() {
+					input = append(input, record)
+				}
+				for start := 0; start < len(input); start += MaximumSyncBatchSize {
+					end := start + MaximumSyncBatchSize
+					if end > len(input) {
+						end = len(input)
+					}
+					_, err := api.CreateOrUpdate(context.Background(), "wide_c", input[start:end], DedupeBy(DedupeByIDField))
+					require.NoError(b, err)
+				}
+			})
+		}
+		b.ReportMetric(float64(peak), "peak-heap-bytes")
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		var peak uint64
+		for i := 0; i < b.N; i++ {
+			peak = peakHeap(func() {
+				_, err := api.SyncStream(context.Background(), "wide_c", wideRecords(n, fields), DedupeBy(DedupeByIDField))
+				require.NoError(b, err)
+			})
+		}
+		b.ReportMetric(float64(peak), "peak-heap-bytes")
+	})
+}
+
+func TestCreateOrUpdateCustomObjects_dryRun(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	api := NewCustomObjectsAPI(client)
+
+	sent := SyncRequest{}
+	results, err := api.CreateOrUpdate(
+		context.Background(), "testObject_c",
+		[]map[string]interface{}{{"email": "nathan@polytomic.com", "firstName": "Nathan"}},
+		Action(ActionUpdateOnly), DryRun(&sent),
+	)
+	require.NoError(t, err)
+	assert.Nil(t, results)
+	assert.Equal(t, ActionUpdateOnly, sent.Action)
+	assert.Len(t, sent.Input, 1)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(), "testObject_c",
+		[]map[string]interface{}{{"email": "nathan@polytomic.com", "nickname": "Nate"}},
+		DryRun(nil),
+	)
+	assert.EqualError(t, err, `record 0: unknown field "nickname"`)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(), "testObject_c",
+		[]map[string]interface{}{{"email": "nathan@polytomic.com"}},
+		DedupeBy(DedupeByIDField), DryRun(nil),
+	)
+	assert.EqualError(t, err, `record 0: missing dedupe field "marketoGUID"`)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(), "testObject_c",
+		[]map[string]interface{}{{"email": "nathan@polytomic.com"}},
+		PartitionName("Europe"),
+	)
+	assert.EqualError(t, err, "custom object sync does not support the PartitionName option")
+
+	// no sync request is made
+	assert.True(t, gock.IsDone())
+}
+
+func TestSyncStream_dryRun(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	api := NewCustomObjectsAPI(client)
+
+	records := make([]map[string]interface{}, MaximumSyncBatchSize+1)
+	for i := range records {
+		records[i] = map[string]interface{}{"email": fmt.Sprintf("lead%d@example.com", i)}
+	}
+	sent := SyncRequest{}
+	results, err := api.SyncStream(context.Background(), "testObject_c", SliceRecordReader(records), DryRun(&sent))
+	require.NoError(t, err)
+	assert.Nil(t, results)
+	assert.True(t, sent.DryRun)
+	assert.Empty(t, sent.Input)
+
+	records[MaximumSyncBatchSize]["nickname"] = "Nate"
+	_, err = api.SyncStream(context.Background(), "testObject_c", SliceRecordReader(records), DryRun(nil))
+	assert.EqualError(t, err, `record 300: unknown field "nickname"`)
+
+	// no sync request is made; gock fails any unmatched request
+	assert.True(t, gock.IsDone())
+}
+
+func TestCustomObjectDescribe_cachedCopy(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/customobjects/testObject_c/describe.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/testObject_c-describe.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	obj, err := NewCustomObjectsAPI(client).Describe(context.Background(), "testObject_c")
+	require.NoError(t, err)
+	obj.Fields[0].Name = "changed"
+	obj.SearchableFields[0][0] = "changed"
+	obj.DedupeFields[0] = "changed"
+
+	// another API using the Client is served the unchanged description
+	cached, err := NewCustomObjectsAPI(client).Describe(context.Background(), "testObject_c")
+	require.NoError(t, err)
+	assert.Equal(t, "createdAt", cached.Fields[0].Name)
+	assert.Equal(t, []string{"email"}, cached.SearchableFields[0])
+	assert.Equal(t, []string{"email"}, cached.DedupeFields)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,93 @@
+package marketo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DataType is the type of a Marketo field, as returned by describe
+type DataType string
+
+const (
+	DataTypeString    DataType = "string"
+	DataTypeText      DataType = "text"
+	DataTypeEmail     DataType = "email"
+	DataTypePhone     DataType = "phone"
+	DataTypeURL       DataType = "url"
+	DataTypeReference DataType = "reference"
+	DataTypeInteger   DataType = "integer"
+	DataTypeScore     DataType = "score"
+	DataTypeFloat     DataType = "float"
+	DataTypeCurrency  DataType = "currency"
+	DataTypePercent   DataType = "percent"
+	DataTypeBoolean   DataType = "boolean"
+	DataTypeDate      DataType = "date"
+	DataTypeDatetime  DataType = "datetime"
+)
+
+var (
+	stringType    = reflect.TypeOf("")
+	intType       = reflect.TypeOf(0)
+	floatType     = reflect.TypeOf(float64(0))
+	boolType      = reflect.TypeOf(false)
+	timeType      = reflect.TypeOf(time.Time{})
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+	dataTypeGoTypes = map[DataType]reflect.Type{
+		DataTypeString:    stringType,
+		DataTypeText:      stringType,
+		DataTypeEmail:     stringType,
+		DataTypePhone:     stringType,
+		DataTypeURL:       stringType,
+		DataTypeReference: stringType,
+		DataTypeInteger:   intType,
+		DataTypeScore:     intType,
+		DataTypeFloat:     floatType,
+		DataTypeCurrency:  floatType,
+		DataTypePercent:   floatType,
+		DataTypeBoolean:   boolType,
+		DataTypeDate:      timeType,
+		DataTypeDatetime:  timeType,
+	}
+)
+
+// ParseDataType returns the DataType for s, ignoring case and surrounding
+// whitespace. Types not known to this package are returned lower cased.
+func ParseDataType(s string) DataType {
+	return DataType(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// UnmarshalJSON decodes the DataType, normalizing it with ParseDataType
+func (d *DataType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*d = ParseDataType(s)
+	return nil
+}
+
+// Known returns true if the DataType is one of the types defined by this
+// package.
+func (d DataType) Known() bool {
+	_, ok := dataTypeGoTypes[d]
+	return ok
+}
+
+// GoType returns the Go type used to represent values of the DataType; date
+// and datetime fields are represented as time.Time. Unknown types are
+// represented as interface{}.
+func (d DataType) GoType() reflect.Type {
+	if t, ok := dataTypeGoTypes[d]; ok {
+		return t
+	}
+	return interfaceType
+}
+
+// ZeroValue returns the zero value of the DataType's GoType; for unknown
+// types it returns nil.
+func (d DataType) ZeroValue() interface{} {
+	return reflect.Zero(d.GoType()).Interface()
+}
@@ -0,0 +1,29 @@
+package marketo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDataType(t *testing.T) {
+	assert.Equal(t, DataTypeDatetime, ParseDataType(" DateTime "))
+	assert.True(t, ParseDataType("Integer").Known())
+	assert.False(t, ParseDataType("formula").Known())
+
+	field := ObjectField{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"score","dataType":"INTEGER"}`), &field))
+	assert.Equal(t, DataTypeInteger, field.DataType)
+}
+
+func TestDataTypeGoType(t *testing.T) {
+	assert.Equal(t, reflect.TypeOf(""), DataTypeEmail.GoType())
+	assert.Equal(t, reflect.TypeOf(time.Time{}), DataTypeDate.GoType())
+	assert.Equal(t, float64(0), DataTypeCurrency.ZeroValue())
+	assert.Equal(t, false, DataTypeBoolean.ZeroValue())
+	assert.Nil(t, DataType("formula").ZeroValue())
+}
@@ -0,0 +1,153 @@
+package marketo
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// traceBodyBytes is the largest request or response body retained in
+	// a TraceEntry; longer bodies are truncated
+	traceBodyBytes = 4096
+	redacted       = "REDACTED"
+)
+
+// redactedParams contains the query parameters whose values are secret
+var redactedParams = []string{"access_token", "client_secret"}
+
+// TraceEntry records a single request made to the REST API, with secrets
+// redacted.
+type TraceEntry struct {
+	Time          time.Time
+	Operation     string
+	Method        string
+	URL           string
+	RequestHeader http.Header
+	// RequestBody contains up to the first 4KB of the request body, if the
+	// body is held in memory; bodies streamed from a caller's reader are
+	// not traced
+	RequestBody string
+	StatusCode  int
+	// ResponseBody contains up to the first 4KB of the response body, if it
+	// was read into memory
+	ResponseBody string
+	Duration     time.Duration
+	Err          error
+}
+
+// debugTrace is a ring buffer holding the most recent TraceEntries
+type debugTrace struct {
+	lock    sync.Mutex
+	entries []TraceEntry
+	next    int
+	full    bool
+}
+
+func newDebugTrace(size int) *debugTrace {
+	if size <= 0 {
+		return nil
+	}
+	return &debugTrace{entries: make([]TraceEntry, size)}
+}
+
+// add records entry, replacing the oldest entry once the buffer is full
+func (t *debugTrace) add(entry TraceEntry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.entries[t.next] = entry
+	t.next = (t.next + 1) % len(t.entries)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// snapshot returns a copy of the entries, oldest first
+func (t *debugTrace) snapshot() []TraceEntry {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.full {
+		return append([]TraceEntry{}, t.entries[:t.next]...)
+	}
+	return append(append([]TraceEntry{}, t.entries[t.next:]...), t.entries[:t.next]...)
+}
+
+// DebugTrace returns the most recent requests made to the REST API, oldest
+// first, if the Client is configured with a DebugTraceSize.
+func (c *Client) DebugTrace() []TraceEntry {
+	if c.trace == nil {
+		return nil
+	}
+	return c.trace.snapshot()
+}
+
+// recordTrace adds the request made for operation to the debug trace, if
+// enabled.
+func (c *Client) recordTrace(operation string, req *http.Request, status int, body []byte, start time.Time, err error) {
+	if c.trace == nil {
+		return
+	}
+
+	u := *req.URL
+	query := u.Query()
+	for _, param := range redactedParams {
+		if query.Get(param) != "" {
+			query.Set(param, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	header := req.Header.Clone()
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", redacted)
+	}
+
+	c.trace.add(TraceEntry{
+		Time:          start,
+		Operation:     operation,
+		Method:        req.Method,
+		URL:           u.String(),
+		RequestHeader: header,
+		RequestBody:   traceRequestBody(req),
+		StatusCode:    status,
+		ResponseBody:  truncate(body),
+		Duration:      time.Since(start),
+		Err:           err,
+	})
+}
+
+// unbufferedBodyKey marks the context of a request whose body is read from a
+// caller's reader rather than held in memory; rewinding it with GetBody would
+// move the caller's reader, and so it is not traced
+type unbufferedBodyKey struct{}
+
+// withUnbufferedBody returns req marked as having an unbuffered body
+func withUnbufferedBody(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), unbufferedBodyKey{}, true))
+}
+
+// traceRequestBody returns the start of the body of req, if it is held in
+// memory and can be rewound
+func traceRequestBody(req *http.Request) string {
+	if req.GetBody == nil || req.Context().Value(unbufferedBodyKey{}) != nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(body, traceBodyBytes))
+	return string(data)
+}
+
+// truncate returns up to the first traceBodyBytes of body
+func truncate(body []byte) string {
+	if len(body) > traceBodyBytes {
+		body = body[:traceBodyBytes]
+	}
+	return string(body)
+}
@@ -0,0 +1,98 @@
+package marketo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDebugTrace(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Times(2).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1000","success":true,"result":[]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1001","success":true,"result":[{"id":1,"status":"created"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:             clientID,
+		Secret:         clientSecret,
+		Endpoint:       testHost,
+		DebugTraceSize: 2,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, client.DebugTrace())
+
+	_, err = client.Get("/rest/v1/leads.json")
+	require.NoError(t, err)
+	_, err = client.Get("/rest/v1/leads.json?access_token=secret", WithHeader("Authorization", "Bearer secret"))
+	require.NoError(t, err)
+	_, err = client.Post("/rest/v1/leads.json", []byte(`{"input":[{"email":"tester@example.com"}]}`))
+	require.NoError(t, err)
+
+	trace := client.DebugTrace()
+	require.Len(t, trace, 2)
+
+	assert.Equal(t, http.MethodGet, trace[0].Method)
+	assert.Equal(t, testHost+"/rest/v1/leads.json?access_token=REDACTED", trace[0].URL)
+	assert.Equal(t, "REDACTED", trace[0].RequestHeader.Get("Authorization"))
+	assert.Equal(t, http.StatusOK, trace[0].StatusCode)
+
+	assert.Equal(t, http.MethodPost, trace[1].Method)
+	assert.Equal(t, `{"input":[{"email":"tester@example.com"}]}`, trace[1].RequestBody)
+	assert.Contains(t, trace[1].ResponseBody, `"requestId":"1001"`)
+	assert.NoError(t, trace[1].Err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDebugTrace_streamedBody(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		BodyString(strings.Repeat("x", traceBodyBytes+100)).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1001","success":true,"result":[]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:             clientID,
+		Secret:         clientSecret,
+		Endpoint:       testHost,
+		DebugTraceSize: 1,
+	})
+	require.NoError(t, err)
+
+	// hide the concrete type so the body is only known to be seekable
+	body := struct{ io.ReadSeeker }{strings.NewReader(strings.Repeat("x", traceBodyBytes+100))}
+	resp, err := client.Stream(context.Background(), http.MethodPost, "/rest/v1/leads.json", body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// tracing neither reads nor seeks the caller's body
+	offset, err := body.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.EqualValues(t, traceBodyBytes+100, offset)
+	trace := client.DebugTrace()
+	require.Len(t, trace, 1)
+	assert.Empty(t, trace[0].RequestBody)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,72 @@
+package marketo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// munchkinPattern matches a Munchkin account ID, for example 123-ABC-456
+var munchkinPattern = regexp.MustCompile(`^[0-9]{3}-[A-Za-z]{3}-[0-9]{3}$`)
+
+// MunchkinEndpoint returns the endpoint of the Marketo instance identified by
+// its Munchkin account ID, for example https://123-ABC-456.mktorest.com.
+// Sandbox and production instances have separate Munchkin IDs.
+func MunchkinEndpoint(munchkin string) (string, error) {
+	if !munchkinPattern.MatchString(munchkin) {
+		return "", fmt.Errorf("invalid Munchkin ID %q: must be of the form 123-ABC-456", munchkin)
+	}
+	return fmt.Sprintf("https://%s.mktorest.com", strings.ToUpper(munchkin)), nil
+}
+
+// ValidateEndpoint returns an error if endpoint is not the base URL of a
+// Marketo instance, such as https://123-ABC-456.mktorest.com. The REST and
+// identity URLs shown in the Marketo admin include a /rest or /identity path,
+// which must be removed.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid endpoint %q: must be an http or https URL", endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: missing host", endpoint)
+	}
+	if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+		return fmt.Errorf("invalid endpoint %q: must not include the path %s", endpoint, path)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid endpoint %q: must not include a query or fragment", endpoint)
+	}
+	return nil
+}
+
+// Endpoints returns the REST and identity base URLs for the Marketo instance
+// at endpoint.
+func Endpoints(endpoint string) (rest, identity string) {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	return endpoint + "/rest", endpoint + identityBase
+}
+
+// configEndpoint returns the endpoint configured by config, derived from the
+// Munchkin ID if no Endpoint is set.
+func configEndpoint(config ClientConfig) (string, error) {
+	endpoint := config.Endpoint
+	if config.Munchkin != "" {
+		if endpoint != "" {
+			return "", errors.New("only one of Endpoint and Munchkin may be set")
+		}
+		var err error
+		if endpoint, err = MunchkinEndpoint(config.Munchkin); err != nil {
+			return "", err
+		}
+	}
+	if err := ValidateEndpoint(endpoint); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(endpoint, "/"), nil
+}
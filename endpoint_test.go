@@ -0,0 +1,73 @@
+package marketo
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestMunchkinEndpoint(t *testing.T) {
+	endpoint, err := MunchkinEndpoint("123-abc-456")
+	require.NoError(t, err)
+	assert.Equal(t, "https://123-ABC-456.mktorest.com", endpoint)
+
+	_, err = MunchkinEndpoint("123abc456")
+	assert.Error(t, err)
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	assert.NoError(t, ValidateEndpoint("https://123-ABC-456.mktorest.com"))
+	assert.NoError(t, ValidateEndpoint("https://123-ABC-456.mktorest.com/"))
+
+	for _, endpoint := range []string{
+		"",
+		"123-ABC-456.mktorest.com",
+		"https://123-ABC-456.mktorest.com/rest",
+		"https://123-ABC-456.mktorest.com/identity",
+		"https://123-ABC-456.mktorest.com?a=b",
+	} {
+		assert.Error(t, ValidateEndpoint(endpoint), endpoint)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	rest, identity := Endpoints("https://123-ABC-456.mktorest.com/")
+	assert.Equal(t, "https://123-ABC-456.mktorest.com/rest", rest)
+	assert.Equal(t, "https://123-ABC-456.mktorest.com/identity", identity)
+}
+
+func TestNewClient_munchkin(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://123-ABC-456.mktorest.com").
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Munchkin: "123-ABC-456",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://123-ABC-456.mktorest.com/rest/v1/leads.json", client.restURL("leads.json"))
+
+	_, err = NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+		Munchkin: "123-ABC-456",
+	})
+	assert.Error(t, err)
+
+	_, err = NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost + "/rest",
+	})
+	assert.Error(t, err)
+	assert.True(t, gock.IsDone())
+}
@@ -2,6 +2,7 @@ package marketo
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -20,6 +21,24 @@ func (r Reason) Error() string {
 	return fmt.Sprintf("%s: %s", r.Code, r.Message)
 }
 
+// UnmarshalJSON decodes a Reason; the asset APIs return warnings as plain
+// strings, which are decoded as a Reason with only a Message.
+func (r *Reason) UnmarshalJSON(data []byte) error {
+	var message string
+	if json.Unmarshal(data, &message) == nil {
+		*r = Reason{Message: message}
+		return nil
+	}
+	type reason Reason
+	return json.Unmarshal(data, (*reason)(r))
+}
+
+// String returns the Reason formatted for logging, for example
+// "[601] Access token invalid".
+func (r Reason) String() string {
+	return fmt.Sprintf("[%s] %s", r.Code, r.Message)
+}
+
 var (
 	ErrBadGateway                    = Reason{Code: "502"}
 	ErrEmptyAccessToken              = Reason{Code: "600"}
@@ -51,16 +70,99 @@ var (
 	ErrUnableToFindDefaultRecordType = Reason{Code: "714"}
 	ErrExternalSalesPersonIDNotFound = Reason{Code: "718"}
 
-	ErrTooManyImports = Reason{Code: "1016"}
+	ErrLeadNotFound              = Reason{Code: "1004"}
+	ErrPartitionAccessDenied     = Reason{Code: "1008"}
+	ErrPartitionNameUnspecified  = Reason{Code: "1009"}
+	ErrPartitionUpdateNotAllowed = Reason{Code: "1010"}
+	ErrInvalidCookie             = Reason{Code: "1012"}
+	ErrObjectNotFound            = Reason{Code: "1013"}
+	ErrTooManyImports            = Reason{Code: "1016"}
 )
 
+// ErrStalePagingToken is matched, using errors.Is, by the error returned when
+// Marketo rejects a paging token because it has expired or is invalid. The
+// caller should restart paging with a fresh token.
+var ErrStalePagingToken = stderrors.New("stale paging token")
+
+// pagingTokenReasons contains the Reason codes Marketo returns for an invalid
+// parameter value, which includes an expired or invalid paging token.
+var pagingTokenReasons = map[string]bool{
+	"1001": true,
+	"1003": true,
+}
+
+// stalePagingTokenError wraps an Error caused by a stale paging token
+type stalePagingTokenError struct {
+	cause Error
+}
+
+func (e stalePagingTokenError) Error() string {
+	return e.cause.Error()
+}
+
+func (e stalePagingTokenError) Unwrap() error {
+	return e.cause
+}
+
+func (e stalePagingTokenError) Is(target error) bool {
+	return target == ErrStalePagingToken
+}
+
+// pagingTokenError returns err, wrapped so that it matches
+// ErrStalePagingToken if Marketo rejected the paging token.
+func pagingTokenError(err error) error {
+	mErr, ok := err.(Error)
+	if !ok {
+		return err
+	}
+	for _, r := range mErr.Errors {
+		if pagingTokenReasons[r.Code] && strings.Contains(strings.ToLower(r.Message), "token") {
+			return stalePagingTokenError{cause: mErr}
+		}
+	}
+	return err
+}
+
+// retryableReasons contains the Reason codes which indicate a transient
+// failure: the same request may succeed if made again later.
+var retryableReasons = map[string]bool{
+	ErrBadGateway.Code:             true,
+	ErrRequestTimeOut.Code:         true,
+	ErrRateLimitExceeded.Code:      true,
+	ErrTemporarilyUnavailable.Code: true,
+	ErrConcurrentLimitReached.Code: true,
+	ErrTransientError.Code:         true,
+}
+
+// Retryable returns true if the Reason indicates a transient failure. The
+// retryable codes are 502 (bad gateway), 604 (request timed out), 606 (rate
+// limit exceeded), 608 (temporarily unavailable), 615 (concurrent request
+// limit reached), and 713 (transient error). 607 (daily quota reached) is not
+// retryable: the quota does not reset until the end of the day.
+func (r Reason) Retryable() bool {
+	return retryableReasons[r.Code]
+}
+
 // Error contains the error state returned from a Marketo operation
 type Error struct {
+	// Operation is the name of the operation which failed, ie "filter
+	// leads"
+	Operation  string
 	Message    string
 	StatusCode int
 	Body       string
+	// RequestID is the requestId Marketo returned with the error, if any
+	RequestID string
 
 	Errors []Reason
+	// Warnings contains any warnings returned alongside the Errors
+	Warnings []Reason
+}
+
+// HTTPStatus returns the status code of the response which caused the Error,
+// if any
+func (e Error) HTTPStatus() int {
+	return e.StatusCode
 }
 
 // ErrorForReasons returns a new Error wrapping the Reasons provided by the
@@ -72,6 +174,23 @@ func ErrorForReasons(status int, reasons ...Reason) Error {
 	}
 }
 
+// operationError returns a new Error wrapping the Reasons returned by Marketo
+// for operation.
+func operationError(operation string, status int, reasons ...Reason) Error {
+	err := ErrorForReasons(status, reasons...)
+	err.Operation = operation
+	return err
+}
+
+// responseError returns a new Error wrapping the Reasons, Warnings and
+// request ID of the response Marketo returned for operation.
+func responseError(operation string, status int, response *Response) Error {
+	err := operationError(operation, status, response.Errors...)
+	err.Warnings = response.Warnings
+	err.RequestID = response.RequestID
+	return err
+}
+
 // Is provides support for the errors.Is() call, and will return true if the
 // passed target is a Reason and it matches any of the Reasons included with
 // this Error.
@@ -86,37 +205,245 @@ func (e Error) Is(target error) bool {
 	return false
 }
 
-// Error fulfills the error interface
+// As provides support for the errors.As() call: if target is a *Reason it is
+// set to the first Reason included with this Error. Use FindReason to
+// retrieve the Reason matching a specific code.
+func (e Error) As(target interface{}) bool {
+	if reason, ok := target.(*Reason); ok && len(e.Errors) > 0 {
+		*reason = e.Errors[0]
+		return true
+	}
+	return false
+}
+
+// FindReason returns the Reason included with this Error whose code matches
+// target, including its Message.
+func (e Error) FindReason(target Reason) (Reason, bool) {
+	for _, r := range e.Errors {
+		if r.Code == target.Code {
+			return r, true
+		}
+	}
+	return Reason{}, false
+}
+
+// Retryable returns true if any of the Reasons included with this Error are
+// retryable.
+func (e Error) Retryable() bool {
+	for _, r := range e.Errors {
+		if r.Retryable() {
+			return true
+		}
+	}
+	return false
+}
+
+// Error fulfills the error interface; if the Operation is known, the message
+// is prefixed with it. When the message is built from the Reasons it is
+// followed by the status code and, if known, the request ID, for example
+// "filter leads: [601] Access token invalid (status 401, requestId abc)".
 func (e Error) Error() string {
-	if e.Message != "" {
-		return e.Message
+	msg := e.Message
+	details := []string{}
+	if msg == "" {
+		reasons := e.Errors
+		if len(reasons) == 0 {
+			reasons = e.Warnings
+		}
+		msgs := make([]string, len(reasons))
+		for i, err := range reasons {
+			msgs[i] = err.String()
+		}
+		msg = strings.Join(msgs, "; ")
+		if e.StatusCode != 0 {
+			details = append(details, fmt.Sprintf("status %d", e.StatusCode))
+		}
+	}
+	if e.RequestID != "" {
+		details = append(details, fmt.Sprintf("requestId %s", e.RequestID))
+	}
+	if len(details) > 0 {
+		msg = fmt.Sprintf("%s (%s)", msg, strings.Join(details, ", "))
 	}
 
+	if e.Operation != "" {
+		return fmt.Sprintf("%s: %s", e.Operation, msg)
+	}
+	return msg
+}
+
+// String returns the Error formatted for logging; it is the same as Error.
+func (e Error) String() string {
+	return e.Error()
+}
+
+// HTTPStatusError is implemented by the errors returned by the package which
+// were caused by an HTTP response, such as Error and AuthError; use errors.As
+// or HTTPStatus to find the status code of the response.
+type HTTPStatusError interface {
+	error
+	HTTPStatus() int
+}
+
+// HTTPStatus returns the status code of the HTTP response which caused err,
+// if any.
+func HTTPStatus(err error) (int, bool) {
+	var sErr HTTPStatusError
+	if errors.As(err, &sErr) {
+		return sErr.HTTPStatus(), true
+	}
+	return 0, false
+}
+
+// statusError wraps an error which occurred handling a response, such as
+// reading its body, with the response's status code
+type statusError struct {
+	operation string
+	status    int
+	err       error
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.operation, e.err)
+}
+
+func (e statusError) Unwrap() error {
+	return e.err
+}
+
+func (e statusError) HTTPStatus() int {
+	return e.status
+}
+
+// AuthError is returned when the identity endpoint rejects a token request
+// with an OAuth error, for example "invalid_client" when the client ID or
+// secret is wrong.
+type AuthError struct {
+	StatusCode int
+	// Code is the OAuth error code, ie "invalid_client" or "invalid_grant"
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	// Body is the response body, set if it could not be decoded
+	Body string
+}
+
+// Error fulfills the error interface
+func (e AuthError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("authentication error: %d %s", e.StatusCode, e.Body)
+	}
+	if e.Description == "" {
+		return fmt.Sprintf("authentication error: %d %s", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("authentication error: %d %s: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// HTTPStatus returns the status code of the identity response
+func (e AuthError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// authError returns an AuthError decoded from the body of a non-successful
+// identity response.
+func authError(status int, body []byte) AuthError {
+	err := AuthError{}
+	if json.Unmarshal(body, &err) != nil || err.Code == "" {
+		err = AuthError{Body: string(body)}
+	}
+	err.StatusCode = status
+	return err
+}
+
+// BatchError is returned when one or more batches of a multi-batch operation,
+// such as LeadAPI.FilterMulti, fail. errors.Is and errors.As match the error
+// of any failed batch.
+type BatchError struct {
+	// Errors contains the error of each failed batch, in the order of the
+	// batches
+	Errors []error
+}
+
+// batchError returns a BatchError containing the non-nil errs, or nil if
+// there are none.
+func batchError(errs []error) error {
+	failed := []error{}
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return BatchError{Errors: failed}
+}
+
+// Error fulfills the error interface
+func (e BatchError) Error() string {
 	msgs := make([]string, len(e.Errors))
 	for i, err := range e.Errors {
-		msgs[i] = err.Message
+		msgs[i] = err.Error()
 	}
-	return strings.Join(msgs, "; ")
+	return fmt.Sprintf("%d batches failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Is provides support for the errors.Is() call, and will return true if the
+// error of any failed batch matches target.
+func (e BatchError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As provides support for the errors.As() call, setting target to the error
+// of the first failed batch which matches it.
+func (e BatchError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 // handleError reads a non-successful HTTP responnse & returns an
 // error wrapping it; it is the callers responsibility to close
-// response.Body.
+// response.Body. Responses returned by doRequest have already been read
+// within the Client's MaxResponseBytes.
 func handleError(operation string, resp *http.Response) error {
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return errors.Wrap(err, "unable to read marketo error response")
+		return statusError{
+			operation: operation,
+			status:    resp.StatusCode,
+			err:       errors.Wrap(err, "unable to read marketo error response"),
+		}
 	}
 
 	// attempt to deserialize the error response
 	response := Response{}
 	err = json.Unmarshal(body, &response)
+	if err == nil && (len(response.Errors) > 0 || len(response.Warnings) > 0) {
+		return responseError(operation, resp.StatusCode, &response)
+	}
 	if err == nil {
-		return ErrorForReasons(resp.StatusCode, response.Errors...)
+		return Error{
+			Operation: operation,
+			Message: fmt.Sprintf(
+				"unexpected status code %d with no errors or warnings", resp.StatusCode,
+			),
+			Body:       string(body),
+			StatusCode: resp.StatusCode,
+			RequestID:  response.RequestID,
+		}
 	}
 
 	return Error{
-		Message:    fmt.Sprintf("error: %s", operation),
+		Operation:  operation,
+		Message:    fmt.Sprintf("unexpected status code %d", resp.StatusCode),
 		Body:       string(body),
 		StatusCode: resp.StatusCode,
 	}
@@ -0,0 +1,161 @@
+package marketo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func errorResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+// errReader is an io.Reader which always fails with err
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestHandleError(t *testing.T) {
+	t.Run("marketo reasons", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusNotFound,
+			`{"success":false,"errors":[{"code":"610","message":"Not found"}]}`,
+		))
+
+		assert.Equal(t, "filter leads: [610] Not found (status 404)", err.Error())
+		assert.Equal(t, filterLeads, err.(Error).Operation)
+	})
+
+	t.Run("errors with warnings", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusBadRequest,
+			`{"success":false,"errors":[{"code":"1003","message":"Invalid field"},{"code":"1006","message":"Field not found"}],"warnings":[{"code":"1007","message":"Multiple leads match"}]}`,
+		))
+
+		mErr := err.(Error)
+		assert.Equal(t, "filter leads: [1003] Invalid field; [1006] Field not found (status 400)", err.Error())
+		assert.Len(t, mErr.Errors, 2)
+		assert.Equal(t, []Reason{{Code: "1007", Message: "Multiple leads match"}}, mErr.Warnings)
+	})
+
+	t.Run("only warnings", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusBadRequest,
+			`{"success":false,"warnings":[{"code":"1007","message":"Multiple leads match"}]}`,
+		))
+
+		assert.Equal(t, "filter leads: [1007] Multiple leads match (status 400)", err.Error())
+	})
+
+	t.Run("request id", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusUnauthorized,
+			`{"requestId":"abc","success":false,"errors":[{"code":"601","message":"Access token invalid"}]}`,
+		))
+
+		assert.Equal(t, "filter leads: [601] Access token invalid (status 401, requestId abc)", err.Error())
+		assert.Equal(t, "abc", err.(Error).RequestID)
+		assert.Equal(t, err.Error(), err.(Error).String())
+	})
+
+	t.Run("no errors or warnings", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusInternalServerError,
+			`{"success":false}`,
+		))
+
+		assert.Equal(t, "filter leads: unexpected status code 500 with no errors or warnings", err.Error())
+		assert.Equal(t, `{"success":false}`, err.(Error).Body)
+	})
+
+	t.Run("unparseable body", func(t *testing.T) {
+		err := handleError(filterLeads, errorResponse(http.StatusBadGateway, "<html></html>"))
+
+		assert.Equal(t, "filter leads: unexpected status code 502", err.Error())
+		assert.Equal(t, "<html></html>", err.(Error).Body)
+	})
+
+	t.Run("unreadable body", func(t *testing.T) {
+		readErr := errors.New("connection reset")
+		err := handleError(filterLeads, &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Body:       ioutil.NopCloser(errReader{err: readErr}),
+		})
+
+		assert.Equal(t, "filter leads: unable to read marketo error response: connection reset", err.Error())
+		assert.True(t, errors.Is(err, readErr))
+		status, ok := HTTPStatus(err)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadGateway, status)
+	})
+}
+
+func TestReasonString(t *testing.T) {
+	reason := Reason{Code: "601", Message: "Access token invalid"}
+	assert.Equal(t, "[601] Access token invalid", reason.String())
+	assert.Equal(t, "601: Access token invalid", reason.Error())
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, ErrRateLimitExceeded.Retryable())
+	assert.True(t, ErrConcurrentLimitReached.Retryable())
+	assert.False(t, ErrNotFound.Retryable())
+	assert.False(t, ErrAccessDenied.Retryable())
+	assert.False(t, ErrDailyQuotaReached.Retryable())
+
+	assert.True(t, ErrorForReasons(http.StatusOK, ErrNotFound, ErrTransientError).Retryable())
+	assert.False(t, ErrorForReasons(http.StatusOK, ErrNotFound).Retryable())
+	assert.False(t, Error{Message: "unexpected"}.Retryable())
+}
+
+func TestPagingTokenError(t *testing.T) {
+	stale := operationError(filterLeads, http.StatusOK, Reason{Code: "1003", Message: "Invalid next page token"})
+	assert.True(t, errors.Is(pagingTokenError(stale), ErrStalePagingToken))
+	assert.Equal(t, stale.Error(), pagingTokenError(stale).Error())
+
+	invalid := operationError(filterLeads, http.StatusOK, Reason{Code: "1003", Message: "Invalid filterType"})
+	assert.False(t, errors.Is(pagingTokenError(invalid), ErrStalePagingToken))
+	assert.False(t, errors.Is(pagingTokenError(ErrorForReasons(http.StatusOK, ErrAccessTokenExpired)), ErrStalePagingToken))
+}
+
+func TestErrorAs(t *testing.T) {
+	var err error = operationError(syncLeads, http.StatusOK,
+		Reason{Code: "1003", Message: "Invalid data"},
+		Reason{Code: "606", Message: "Max rate limit '100' exceeded with in '20' secs"},
+	)
+
+	var reason Reason
+	require.True(t, errors.As(err, &reason))
+	assert.Equal(t, "1003", reason.Code)
+
+	var mErr Error
+	require.True(t, errors.As(err, &mErr))
+	matched, ok := mErr.FindReason(ErrRateLimitExceeded)
+	require.True(t, ok)
+	assert.Equal(t, "Max rate limit '100' exceeded with in '20' secs", matched.Message)
+
+	_, ok = mErr.FindReason(ErrNotFound)
+	assert.False(t, ok)
+}
+
+func TestAuthError(t *testing.T) {
+	err := authError(http.StatusUnauthorized, []byte(`{"error":"invalid_grant","error_description":"Invalid scope"}`))
+	assert.Equal(t, AuthError{StatusCode: 401, Code: "invalid_grant", Description: "Invalid scope"}, err)
+	assert.Equal(t, "authentication error: 401 invalid_grant: Invalid scope", err.Error())
+
+	err = authError(http.StatusBadRequest, []byte("Bad Request"))
+	assert.Equal(t, AuthError{StatusCode: 400, Body: "Bad Request"}, err)
+	assert.Equal(t, "authentication error: 400 Bad Request", err.Error())
+
+	status, ok := HTTPStatus(fmt.Errorf("refresh: %w", err))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
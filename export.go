@@ -0,0 +1,309 @@
+package marketo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ExportObject identifies the type of records exported by a bulk export
+type ExportObject struct {
+	path string
+}
+
+var (
+	// LeadExport exports Leads
+	LeadExport = ExportObject{path: "leads/export"}
+	// ActivityExport exports Lead Activities
+	ActivityExport = ExportObject{path: "activities/export"}
+)
+
+// CustomObjectExport exports the custom object with the provided API name
+func CustomObjectExport(apiName string) ExportObject {
+	return ExportObject{path: "customobjects/" + apiName + "/export"}
+}
+
+const (
+	ExportCreated    = "Created"
+	ExportQueued     = "Queued"
+	ExportProcessing = "Processing"
+	ExportCanceled   = "Canceled"
+	ExportCompleted  = "Completed"
+	ExportFailed     = "Failed"
+)
+
+const (
+	createExport     = "create bulk export"
+	enqueueExport    = "enqueue bulk export"
+	getExport        = "get export status"
+	getExportFile    = "get export file"
+	cancelExport     = "cancel bulk export"
+	exportFileFormat = "CSV"
+)
+
+// DateRange limits an export to records with a date between StartAt and
+// EndAt
+type DateRange struct {
+	StartAt time.Time `json:"startAt"`
+	EndAt   time.Time `json:"endAt"`
+}
+
+// ExportFilter limits the records included in an export
+type ExportFilter struct {
+	CreatedAt       *DateRange `json:"createdAt,omitempty"`
+	UpdatedAt       *DateRange `json:"updatedAt,omitempty"`
+	StaticListID    int        `json:"staticListId,omitempty"`
+	SmartListID     int        `json:"smartListId,omitempty"`
+	ActivityTypeIDs []int      `json:"activityTypeIds,omitempty"`
+}
+
+// ExportJob contains the details of a bulk export, returned by the Create,
+// Enqueue, and Status functions
+type ExportJob struct {
+	ExportID        string     `json:"exportId"`
+	Format          string     `json:"format"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	QueuedAt        *time.Time `json:"queuedAt,omitempty"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	NumberOfRecords int        `json:"numberOfRecords,omitempty"`
+	FileSize        int64      `json:"fileSize,omitempty"`
+	FileChecksum    string     `json:"fileChecksum,omitempty"`
+	ErrorMessage    string     `json:"errorMsg,omitempty"`
+}
+
+// exportRequest is the payload used to create a bulk export
+type exportRequest struct {
+	Fields []string     `json:"fields,omitempty"`
+	Format string       `json:"format"`
+	Filter ExportFilter `json:"filter"`
+}
+
+// ExportAPI provides access to the Marketo bulk export API
+type ExportAPI struct {
+	*Client
+}
+
+// NewExportAPI returns a new instance of the export API, configured with the
+// provided Client.
+func NewExportAPI(c *Client) *ExportAPI {
+	return &ExportAPI{c}
+}
+
+// Create creates a new export job for the fields of the records matching
+// filter; the job must be enqueued before Marketo begins processing it.
+func (e *ExportAPI) Create(ctx context.Context, obj ExportObject, fields []string, filter ExportFilter) (*ExportJob, error) {
+	body, err := json.Marshal(exportRequest{
+		Fields: fields,
+		Format: exportFileFormat,
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e.job(ctx, createExport, http.MethodPost,
+		e.url("bulk", "v1", obj.path, "create.json"), body,
+	)
+}
+
+// CreateCustomObjectJob creates a new export job for the fields of the custom
+// objects matching filter. The returned job is managed by passing
+// CustomObjectExport(apiName) to Enqueue, Status, and StreamFile.
+func (e *ExportAPI) CreateCustomObjectJob(ctx context.Context, apiName string, fields []string, filter ExportFilter) (*ExportJob, error) {
+	return e.Create(ctx, CustomObjectExport(apiName), fields, filter)
+}
+
+// Enqueue queues an export job for processing
+func (e *ExportAPI) Enqueue(ctx context.Context, obj ExportObject, exportID string) (*ExportJob, error) {
+	return e.job(ctx, enqueueExport, http.MethodPost,
+		e.url("bulk", "v1", obj.path, exportID, "enqueue.json"), nil,
+	)
+}
+
+// Status retrieves the current state of an export job
+func (e *ExportAPI) Status(ctx context.Context, obj ExportObject, exportID string) (*ExportJob, error) {
+	return e.job(ctx, getExport, http.MethodGet,
+		e.url("bulk", "v1", obj.path, exportID, "status.json"), nil,
+	)
+}
+
+// ErrExportFinished is matched, using errors.Is, by the ExportFinishedError
+// returned when cancelling an export job which has already finished.
+var ErrExportFinished = errors.New("export already finished")
+
+// ExportFinishedError is returned by Cancel when the export job has already
+// completed, failed or been canceled, and so cannot be canceled.
+type ExportFinishedError struct {
+	ExportID string
+	Status   string
+}
+
+func (e ExportFinishedError) Error() string {
+	return fmt.Sprintf("export %s already finished: %s", e.ExportID, e.Status)
+}
+
+// Is provides support for errors.Is, matching ErrExportFinished
+func (e ExportFinishedError) Is(target error) bool {
+	return target == ErrExportFinished
+}
+
+// exportFinished returns true if status is a terminal export job status
+func exportFinished(status string) bool {
+	switch status {
+	case ExportCompleted, ExportFailed, ExportCanceled:
+		return true
+	}
+	return false
+}
+
+// Cancel cancels a created, queued or processing export job, freeing its
+// place in the queue. If the job has already finished, it is returned along
+// with an ExportFinishedError.
+func (e *ExportAPI) Cancel(ctx context.Context, obj ExportObject, exportID string) (*ExportJob, error) {
+	job, err := e.job(ctx, cancelExport, http.MethodPost,
+		e.url("bulk", "v1", obj.path, exportID, "cancel.json"), nil,
+	)
+	var mErr Error
+	if errors.As(err, &mErr) {
+		// Marketo rejects cancelling a job which has finished; report
+		// its status if so
+		if status, sErr := e.Status(ctx, obj, exportID); sErr == nil && exportFinished(status.Status) {
+			return status, ExportFinishedError{ExportID: exportID, Status: status.Status}
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != ExportCanceled && exportFinished(job.Status) {
+		return job, ExportFinishedError{ExportID: exportID, Status: job.Status}
+	}
+	return job, nil
+}
+
+// job makes a request to an endpoint returning an export job
+func (e *ExportAPI) job(ctx context.Context, operation, method, url string, body []byte) (*ExportJob, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := e.Client.doRequest(operation, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(operation, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, responseError(operation, resp.StatusCode, response)
+	}
+
+	jobs := []ExportJob{}
+	err = json.Unmarshal(response.Result, &jobs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) < 1 {
+		return nil, errors.New("not found")
+	}
+	return &jobs[0], nil
+}
+
+// StreamFile returns the contents of a completed export; the caller is
+// responsible for closing it. The file is requested gzip compressed and
+// decompressed transparently as it is read.
+func (e *ExportAPI) StreamFile(ctx context.Context, obj ExportObject, exportID string) (io.ReadCloser, error) {
+	return e.StreamFileFrom(ctx, obj, exportID, 0)
+}
+
+// StreamFileCompressed returns the contents of a completed export without
+// decompressing them. Marketo may choose not to compress the file; compressed
+// reports whether the returned contents are gzip compressed. The caller is
+// responsible for closing the returned file.
+func (e *ExportAPI) StreamFileCompressed(ctx context.Context, obj ExportObject, exportID string) (file io.ReadCloser, compressed bool, err error) {
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, e.url("bulk", "v1", obj.path, exportID, "file.json"), nil,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	// setting Accept-Encoding disables transparent decompression
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := e.Client.doRequest(getExportFile, request)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, false, handleError(getExportFile, resp)
+	}
+	return resp.Body, strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip"), nil
+}
+
+// StreamFileFrom returns the contents of a completed export, starting at
+// offset bytes into the file. This allows an interrupted download to be
+// resumed. When resuming, the file is requested uncompressed so that offset
+// refers to the file contents. The caller is responsible for closing the
+// returned file.
+func (e *ExportAPI) StreamFileFrom(ctx context.Context, obj ExportObject, exportID string, offset int64) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, e.url("bulk", "v1", obj.path, exportID, "file.json"), nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		// byte ranges of a compressed response apply to the compressed
+		// bytes
+		request.Header.Set("Accept-Encoding", "identity")
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := e.Client.doRequest(getExportFile, request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		return resp.Body, nil
+
+	case resp.StatusCode == http.StatusOK:
+		// the Range header was ignored and the entire file returned
+		if offset > 0 {
+			if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close()
+	return nil, handleError(getExportFile, resp)
+}
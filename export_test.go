@@ -0,0 +1,305 @@
+package marketo
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const exportFile = "email,firstName\nnathan@polytomic.com,Nathan\n"
+
+func TestExportLifecycle(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/leads/export/create.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"fields": []string{"email", "firstName"},
+			"format": "CSV",
+			"filter": map[string]interface{}{
+				"createdAt": map[string]interface{}{
+					"startAt": "2021-01-01T00:00:00Z",
+					"endAt":   "2021-01-31T00:00:00Z",
+				},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"exportId":"ce45a7a1","format":"CSV","status":"Created","createdAt":"2021-02-01T10:00:00Z"}]}`)
+	gock.New(testHost).
+		Post("/bulk/v1/leads/export/ce45a7a1/enqueue.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[{"exportId":"ce45a7a1","format":"CSV","status":"Queued","createdAt":"2021-02-01T10:00:00Z"}]}`)
+	gock.New(testHost).
+		Get("/bulk/v1/leads/export/ce45a7a1/status.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"3","success":true,"result":[{"exportId":"ce45a7a1","format":"CSV","status":"Completed","createdAt":"2021-02-01T10:00:00Z","numberOfRecords":1,"fileSize":46}]}`)
+	gock.New(testHost).
+		Get("/bulk/v1/leads/export/ce45a7a1/file.json").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "text/csv").
+		BodyString(exportFile)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	api := NewExportAPI(client)
+	ctx := context.Background()
+	job, err := api.Create(ctx, LeadExport, []string{"email", "firstName"}, ExportFilter{
+		CreatedAt: &DateRange{
+			StartAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ExportCreated, job.Status)
+
+	job, err = api.Enqueue(ctx, LeadExport, job.ExportID)
+	require.NoError(t, err)
+	assert.Equal(t, ExportQueued, job.Status)
+
+	job, err = api.Status(ctx, LeadExport, job.ExportID)
+	require.NoError(t, err)
+	assert.Equal(t, ExportCompleted, job.Status)
+	assert.Equal(t, 1, job.NumberOfRecords)
+
+	file, err := api.StreamFile(ctx, LeadExport, job.ExportID)
+	require.NoError(t, err)
+	defer file.Close()
+	body, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, exportFile, string(body))
+	assert.True(t, gock.IsDone())
+}
+
+func TestExportStreamFileFrom(t *testing.T) {
+	t.Run("partial content", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			Reply(http.StatusOK).
+			JSON(authResponseSuccess)
+		gock.New(testHost).
+			Get("/bulk/v1/leads/export/ce45a7a1/file.json").
+			MatchHeader("Range", "bytes=16-").
+			Reply(http.StatusPartialContent).
+			SetHeader("Content-Type", "text/csv").
+			BodyString(exportFile[16:])
+
+		client, err := NewClient(ClientConfig{
+			ID:       clientID,
+			Secret:   clientSecret,
+			Endpoint: testHost,
+		})
+		require.NoError(t, err)
+
+		file, err := NewExportAPI(client).StreamFileFrom(context.Background(), LeadExport, "ce45a7a1", 16)
+		require.NoError(t, err)
+		defer file.Close()
+		body, err := ioutil.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, exportFile[16:], string(body))
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("range ignored", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(testHost).
+			Get("/identity/oauth/token").
+			Reply(http.StatusOK).
+			JSON(authResponseSuccess)
+		gock.New(testHost).
+			Get("/bulk/v1/leads/export/ce45a7a1/file.json").
+			Reply(http.StatusOK).
+			SetHeader("Content-Type", "text/csv").
+			BodyString(exportFile)
+
+		client, err := NewClient(ClientConfig{
+			ID:       clientID,
+			Secret:   clientSecret,
+			Endpoint: testHost,
+		})
+		require.NoError(t, err)
+
+		file, err := NewExportAPI(client).StreamFileFrom(context.Background(), LeadExport, "ce45a7a1", 16)
+		require.NoError(t, err)
+		defer file.Close()
+		body, err := ioutil.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, exportFile[16:], string(body))
+		assert.True(t, gock.IsDone())
+	})
+}
+
+func TestCustomObjectExport(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/customobjects/testObject_c/export/create.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"fields": []string{"marketoGUID", "email"},
+			"format": "CSV",
+			"filter": map[string]interface{}{
+				"updatedAt": map[string]interface{}{
+					"startAt": "2021-01-01T00:00:00Z",
+					"endAt":   "2021-01-31T00:00:00Z",
+				},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"exportId":"d82f3a64","format":"CSV","status":"Created","createdAt":"2021-02-01T10:00:00Z"}]}`)
+	gock.New(testHost).
+		Post("/bulk/v1/customobjects/testObject_c/export/d82f3a64/enqueue.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[{"exportId":"d82f3a64","format":"CSV","status":"Queued","createdAt":"2021-02-01T10:00:00Z"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	api := NewExportAPI(client)
+	ctx := context.Background()
+	job, err := api.CreateCustomObjectJob(ctx, "testObject_c", []string{"marketoGUID", "email"}, ExportFilter{
+		UpdatedAt: &DateRange{
+			StartAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+
+	job, err = api.Enqueue(ctx, CustomObjectExport("testObject_c"), job.ExportID)
+	require.NoError(t, err)
+	assert.Equal(t, ExportQueued, job.Status)
+	assert.True(t, gock.IsDone())
+}
+
+func TestExportStreamFileCompression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(authResponseSuccess, token)))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		if r.Header.Get("Range") != "" {
+			assert.Equal(t, "identity", r.Header.Get("Accept-Encoding"))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(exportFile[16:]))
+			return
+		}
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(exportFile))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(t, err)
+	api := NewExportAPI(client)
+	ctx := context.Background()
+
+	t.Run("decompressed", func(t *testing.T) {
+		file, err := api.StreamFile(ctx, LeadExport, "ce45a7a1")
+		require.NoError(t, err)
+		defer file.Close()
+		body, err := ioutil.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, exportFile, string(body))
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		file, compressed, err := api.StreamFileCompressed(ctx, LeadExport, "ce45a7a1")
+		require.NoError(t, err)
+		defer file.Close()
+		assert.True(t, compressed)
+		zr, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, exportFile, string(body))
+	})
+
+	t.Run("resumed uncompressed", func(t *testing.T) {
+		file, err := api.StreamFileFrom(ctx, LeadExport, "ce45a7a1", 16)
+		require.NoError(t, err)
+		defer file.Close()
+		body, err := ioutil.ReadAll(file)
+		require.NoError(t, err)
+		assert.Equal(t, exportFile[16:], string(body))
+	})
+}
+
+func TestExportCancel(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/bulk/v1/leads/export/ce45a7a1/cancel.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"exportId":"ce45a7a1","format":"CSV","status":"Canceled","createdAt":"2021-02-01T10:00:00Z"}]}`)
+	gock.New(testHost).
+		Post("/bulk/v1/leads/export/b1a2c3d4/cancel.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":false,"errors":[{"code":"1029","message":"Export job not in cancellable state"}]}`)
+	gock.New(testHost).
+		Get("/bulk/v1/leads/export/b1a2c3d4/status.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"3","success":true,"result":[{"exportId":"b1a2c3d4","format":"CSV","status":"Completed","createdAt":"2021-02-01T10:00:00Z"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewExportAPI(client)
+
+	job, err := api.Cancel(context.Background(), LeadExport, "ce45a7a1")
+	require.NoError(t, err)
+	assert.Equal(t, ExportCanceled, job.Status)
+
+	job, err = api.Cancel(context.Background(), LeadExport, "b1a2c3d4")
+	assert.True(t, errors.Is(err, ErrExportFinished), err)
+	assert.Equal(t, ExportFinishedError{ExportID: "b1a2c3d4", Status: ExportCompleted}, err)
+	require.NotNil(t, job)
+	assert.Equal(t, ExportCompleted, job.Status)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,159 @@
+package marketo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	browseForms   = "browse forms"
+	getForm       = "get form"
+	getFormFields = "get form fields"
+)
+
+// AssetFolder identifies the folder or program containing an asset
+type AssetFolder struct {
+	Type       string `json:"type"`
+	Value      int    `json:"value"`
+	FolderName string `json:"folderName"`
+}
+
+// Form is a Marketo form asset. Timestamps are returned as provided by the
+// asset API, ie "2016-11-10T19:25:45Z+0000", which is not RFC 3339.
+type Form struct {
+	ID                   int         `json:"id"`
+	Name                 string      `json:"name"`
+	Description          string      `json:"description"`
+	CreatedAt            string      `json:"createdAt"`
+	UpdatedAt            string      `json:"updatedAt"`
+	URL                  string      `json:"url"`
+	Status               string      `json:"status"`
+	Folder               AssetFolder `json:"folder"`
+	Language             string      `json:"language"`
+	Locale               string      `json:"locale"`
+	ProgressiveProfiling bool        `json:"progressiveProfiling"`
+	LabelPosition        string      `json:"labelPosition"`
+	ButtonLabel          string      `json:"buttonLabel"`
+	WaitingLabel         string      `json:"waitingLabel"`
+}
+
+// FormField is a single field of a form, including its validation settings
+type FormField struct {
+	ID                string `json:"id"`
+	Label             string `json:"label"`
+	DataType          string `json:"dataType"`
+	Required          bool   `json:"required"`
+	ValidationMessage string `json:"validationMessage"`
+	MaxLength         int    `json:"maxLength"`
+	Instructions      string `json:"instructions"`
+	FormPrefill       bool   `json:"formPrefill"`
+	DefaultValue      string `json:"defaultValue"`
+	// FieldMetaData contains the type specific settings of the field, ie
+	// the values of a select
+	FieldMetaData json.RawMessage `json:"fieldMetaData,omitempty"`
+	// VisibilityRules contains the rules controlling when the field is
+	// shown
+	VisibilityRules json.RawMessage `json:"visibilityRules,omitempty"`
+}
+
+// FormsAPI provides read access to the Marketo forms asset API
+type FormsAPI struct {
+	*Client
+}
+
+// NewFormsAPI returns a new instance of the forms API, configured with the
+// provided Client.
+func NewFormsAPI(c *Client) *FormsAPI {
+	return &FormsAPI{c}
+}
+
+// Browse returns up to maxReturn forms, starting at offset; a maxReturn of 0
+// returns DefaultBrowsePageSize forms.
+func (f *FormsAPI) Browse(ctx context.Context, offset, maxReturn int) ([]Form, error) {
+	values := url.Values{"offset": {strconv.Itoa(offset)}}
+	if maxReturn > 0 {
+		values.Set("maxReturn", strconv.Itoa(maxReturn))
+	}
+	forms := []Form{}
+	err := f.get(ctx, browseForms, f.assetURL("forms.json")+"?"+values.Encode(), &forms)
+	return forms, err
+}
+
+// All returns an Iterator over all forms, fetching pageSize forms at a time;
+// each Value is a Form.
+func (f *FormsAPI) All(ctx context.Context, pageSize int) (*Iterator, error) {
+	return BrowseAll(ctx, func(ctx context.Context, offset, maxReturn int) ([]interface{}, error) {
+		forms, err := f.Browse(ctx, offset, maxReturn)
+		if err != nil {
+			return nil, err
+		}
+		page := make([]interface{}, len(forms))
+		for i, form := range forms {
+			page[i] = form
+		}
+		return page, nil
+	}, pageSize)
+}
+
+// Get returns the form with the provided ID
+func (f *FormsAPI) Get(ctx context.Context, id int) (*Form, error) {
+	forms := []Form{}
+	err := f.get(ctx, getForm, f.assetURL("form", fmt.Sprintf("%d.json", id)), &forms)
+	if err != nil {
+		return nil, err
+	}
+	if len(forms) == 0 {
+		return nil, errors.New("not found")
+	}
+	return &forms[0], nil
+}
+
+// GetFields returns the fields of the form with the provided ID
+func (f *FormsAPI) GetFields(ctx context.Context, formID int) ([]FormField, error) {
+	fields := []FormField{}
+	err := f.get(ctx, getFormFields, f.assetURL("form", strconv.Itoa(formID), "fields.json"), &fields)
+	return fields, err
+}
+
+// assetURL returns the url for paths in the asset API
+func (f *FormsAPI) assetURL(paths ...string) string {
+	return f.url(append([]string{"rest", "asset", "v1"}, paths...)...)
+}
+
+// get decodes the result of the request to path into result. The asset API
+// omits the result, with a warning, when nothing matches; result is left
+// unchanged in that case.
+func (f *FormsAPI) get(ctx context.Context, operation, path string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.Client.doRequest(operation, request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return handleError(operation, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return responseError(operation, resp.StatusCode, response)
+	}
+	if len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,102 @@
+package marketo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestFormsAll(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/asset/v1/forms.json").
+		MatchParam("offset", "0").
+		MatchParam("maxReturn", "2").
+		Reply(http.StatusOK).
+		JSON(`{"success":true,"requestId":"1","result":[` +
+			`{"id":1001,"name":"Contact Us","createdAt":"2016-11-10T19:25:45Z+0000","status":"approved","folder":{"type":"Folder","value":12,"folderName":"Forms"}},` +
+			`{"id":1002,"name":"Webinar Registration","status":"draft","folder":{"type":"Program","value":1033,"folderName":"Webinar"}}` +
+			`]}`)
+	gock.New(testHost).
+		Get("/rest/asset/v1/forms.json").
+		MatchParam("offset", "2").
+		MatchParam("maxReturn", "2").
+		Reply(http.StatusOK).
+		JSON(`{"success":true,"requestId":"2","result":[{"id":1003,"name":"Newsletter","status":"approved"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	forms, err := NewFormsAPI(client).All(context.Background(), 2)
+	require.NoError(t, err)
+	names := []string{}
+	for forms.Next() {
+		names = append(names, forms.Value().(Form).Name)
+	}
+	require.NoError(t, forms.Err())
+	assert.Equal(t, []string{"Contact Us", "Webinar Registration", "Newsletter"}, names)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFormsGet(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/asset/v1/form/1001.json").
+		Reply(http.StatusOK).
+		JSON(`{"success":true,"requestId":"1","result":[{"id":1001,"name":"Contact Us","status":"approved","folder":{"type":"Folder","value":12,"folderName":"Forms"}}]}`)
+	gock.New(testHost).
+		Get("/rest/asset/v1/form/1001/fields.json").
+		Reply(http.StatusOK).
+		JSON(`{"success":true,"requestId":"2","result":[` +
+			`{"id":"Email","label":"Email Address:","dataType":"email","required":true,"validationMessage":"Must be valid email.","maxLength":255,"formPrefill":true,"visibilityRules":{"ruleType":"alwaysShow"}},` +
+			`{"id":"Country","label":"Country:","dataType":"select","required":false,"fieldMetaData":{"values":[{"label":"USA","value":"USA"}]}}` +
+			`]}`)
+	gock.New(testHost).
+		Get("/rest/asset/v1/form/9999.json").
+		Reply(http.StatusOK).
+		JSON(`{"success":true,"requestId":"3","warnings":["No assets found for the given search criteria."]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewFormsAPI(client)
+
+	form, err := api.Get(context.Background(), 1001)
+	require.NoError(t, err)
+	assert.Equal(t, "Contact Us", form.Name)
+	assert.Equal(t, AssetFolder{Type: "Folder", Value: 12, FolderName: "Forms"}, form.Folder)
+
+	fields, err := api.GetFields(context.Background(), 1001)
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+	assert.Equal(t, "Email", fields[0].ID)
+	assert.True(t, fields[0].Required)
+	assert.Equal(t, "Must be valid email.", fields[0].ValidationMessage)
+	assert.JSONEq(t, `{"ruleType":"alwaysShow"}`, string(fields[0].VisibilityRules))
+	assert.False(t, fields[1].Required)
+
+	_, err = api.Get(context.Background(), 9999)
+	assert.EqualError(t, err, "not found")
+	assert.True(t, gock.IsDone())
+}
@@ -1,11 +1,18 @@
 package marketo
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -22,6 +29,57 @@ type LeadResult struct {
 	Fields map[string]string `json:"-" mapstructure:",remain"`
 }
 
+// MarshalJSON encodes the Lead as a single object, with Fields alongside
+// the known fields.
+func (l LeadResult) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, len(l.Fields)+6)
+	for k, v := range l.Fields {
+		obj[k] = v
+	}
+	obj["id"] = l.ID
+	obj["firstName"] = l.FirstName
+	obj["lastName"] = l.LastName
+	obj["email"] = l.Email
+	obj["createdAt"] = l.Created
+	obj["updatedAt"] = l.Updated
+	return json.Marshal(obj)
+}
+
+// values returns the Lead's fields, including the id and any non-empty known
+// fields, by name
+func (l LeadResult) values() map[string]string {
+	values := make(map[string]string, len(l.Fields)+6)
+	for k, v := range l.Fields {
+		values[k] = v
+	}
+	values["id"] = strconv.Itoa(l.ID)
+	known := map[string]string{
+		"firstName": l.FirstName,
+		"lastName":  l.LastName,
+		"email":     l.Email,
+		"createdAt": l.Created,
+		"updatedAt": l.Updated,
+	}
+	for k, v := range known {
+		if v != "" {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// UnmarshalJSON decodes a Lead object, collecting any unknown fields in
+// Fields.
+func (l *LeadResult) UnmarshalJSON(data []byte) error {
+	raw := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return mapstructure.Decode(raw, l)
+}
+
 // LeadAttributeMap defines the name & readonly state of a Lead Attribute
 type LeadAttributeMap struct {
 	Name     string `json:"name"`
@@ -30,7 +88,7 @@ type LeadAttributeMap struct {
 
 // LeadAttribute is returned by the Describe Leads endpoint
 type LeadAttribute struct {
-	DataType    string           `json:"dataType"`
+	DataType    DataType         `json:"dataType"`
 	DisplayName string           `json:"displayName"`
 	ID          int              `json:"id"`
 	Length      int              `json:"length"`
@@ -41,16 +99,81 @@ type LeadAttribute struct {
 // LeadAttribute2 defines a lead attribute defined by the describe2.json
 // endpoint.
 type LeadAttribute2 struct {
-	Name        string `json:"name,omitempty"`
-	DisplayName string `json:"displayName,omitempty"`
-	DataType    string `json:"dataType,omitempty"`
-	Length      int    `json:"length,omitempty"`
-	Updateable  bool   `json:"updateable,omitempty"`
-	CRMManaged  bool   `json:"crmManaged,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	DataType    DataType `json:"dataType,omitempty"`
+	Length      int      `json:"length,omitempty"`
+	Updateable  bool     `json:"updateable,omitempty"`
+	CRMManaged  bool     `json:"crmManaged,omitempty"`
+	// Values contains the allowed values of a picklist field, if Marketo
+	// returns them
+	Values []string `json:"values,omitempty"`
 
 	Searchable bool `json:"searchable,omitempty"`
 }
 
+// copyLeadAttributes returns a copy of fields, including their Values
+func copyLeadAttributes(fields []LeadAttribute2) []LeadAttribute2 {
+	copied := make([]LeadAttribute2, len(fields))
+	for i, f := range fields {
+		f.Values = append([]string(nil), f.Values...)
+		copied[i] = f
+	}
+	return copied
+}
+
+// FieldNames returns the names of the Lead fields
+func FieldNames(fields []LeadAttribute2) []string {
+	return fieldNames(fields, func(LeadAttribute2) bool { return true })
+}
+
+// UpdateableFieldNames returns the names of the Lead fields which may be
+// updated
+func UpdateableFieldNames(fields []LeadAttribute2) []string {
+	return fieldNames(fields, func(f LeadAttribute2) bool { return f.Updateable })
+}
+
+// Writable returns true if the field may be written: it is updateable and,
+// for instances synced with a CRM, not managed by the CRM. Writing a
+// CRM-managed field fails with ErrBusinessRuleViolation.
+func (a LeadAttribute2) Writable() bool {
+	return a.Updateable && !a.CRMManaged
+}
+
+// WritableFields returns the Lead fields which may be written
+func WritableFields(fields []LeadAttribute2) []LeadAttribute2 {
+	writable := []LeadAttribute2{}
+	for _, f := range fields {
+		if f.Writable() {
+			writable = append(writable, f)
+		}
+	}
+	return writable
+}
+
+// WritableFieldNames returns the names of the Lead fields which may be
+// written
+func WritableFieldNames(fields []LeadAttribute2) []string {
+	return fieldNames(fields, LeadAttribute2.Writable)
+}
+
+// SearchableFieldNames returns the names of the Lead fields which may be used
+// to filter Leads
+func SearchableFieldNames(fields []LeadAttribute2) []string {
+	return fieldNames(fields, func(f LeadAttribute2) bool { return f.Searchable })
+}
+
+// fieldNames returns the names of fields for which include returns true
+func fieldNames(fields []LeadAttribute2, include func(LeadAttribute2) bool) []string {
+	names := []string{}
+	for _, f := range fields {
+		if include(f) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
 // leadDescribe2Response contains the envelope used to deserialize a call to
 // describe2.json
 type leadDescribe2Response struct {
@@ -62,8 +185,65 @@ type leadDescribe2Response struct {
 const (
 	describeLead2 = "describe2 lead"
 	filterLeads   = "filter leads"
+	syncLeads     = "sync leads"
+	pushLeads     = "push leads"
+	associateLead = "associate lead"
+	deleteLeads   = "delete leads"
+	getLead       = "get lead"
+
+	leadDescribeKind = "lead"
+)
+
+// Standard Lead fields which control whether a Lead receives marketing email.
+// Marketo applies business rules when these are synced, for example
+// resubscribing a Lead requires unsubscribed to be set to false, and the
+// boolean fields must be sent as JSON booleans: Marketo treats the string
+// "false" as true.
+const (
+	LeadFieldUnsubscribed             = "unsubscribed"
+	LeadFieldUnsubscribedReason       = "unsubscribedReason"
+	LeadFieldMarketingSuspended       = "marketingSuspended"
+	LeadFieldMarketingSuspendedReason = "marketingSuspendedReason"
+	LeadFieldEmailInvalid             = "emailInvalid"
+	LeadFieldEmailInvalidCause        = "emailInvalidCause"
 )
 
+// leadDescribeKey identifies the Lead schema in the Client's describe cache
+var leadDescribeKey = describeKey{kind: leadDescribeKind}
+
+// booleanLeadFields contains the standard boolean Lead fields, which are
+// converted to booleans when synced whether or not the Lead fields have been
+// described
+var booleanLeadFields = map[string]bool{
+	LeadFieldUnsubscribed:       true,
+	LeadFieldMarketingSuspended: true,
+	LeadFieldEmailInvalid:       true,
+}
+
+// LeadService is the set of Lead operations provided by LeadAPI; code which
+// depends on it may substitute a fake in tests.
+type LeadService interface {
+	DescribeFields(ctx context.Context) ([]LeadAttribute2, error)
+	ClearDescribeCache()
+	DescribeCacheStats() DescribeCacheStats
+	GetByID(ctx context.Context, id int, fields ...string) (*LeadResult, error)
+	GetByIDs(ctx context.Context, ids []int, fields ...string) ([]LeadResult, error)
+	GetByIDsWith(ctx context.Context, ids []int, opts ...QueryOption) ([]LeadResult, error)
+	FilterMulti(ctx context.Context, field string, values []string, fields ...string) ([]LeadResult, error)
+	FilterMultiWith(ctx context.Context, field string, values []string, opts ...QueryOption) ([]LeadResult, error)
+	Filter(ctx context.Context, opts ...QueryOption) ([]LeadResult, string, error)
+	FilterRaw(ctx context.Context, opts ...QueryOption) (*Response, error)
+	ChangedSince(ctx context.Context, since time.Time, fields ...string) (*LeadIterator, error)
+	ChangedAfter(ctx context.Context, token string, fields ...string) *LeadIterator
+	CreateOrUpdate(ctx context.Context, leads []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error)
+	Push(ctx context.Context, programName string, leads []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error)
+	Associate(ctx context.Context, leadID int, cookie string) error
+	Delete(ctx context.Context, ids []int) ([]RecordResult, error)
+	DeleteBy(ctx context.Context, field string, values []string) ([]RecordResult, error)
+}
+
+var _ LeadService = (*LeadAPI)(nil)
+
 // LeadAPI provides access to the Marketo Lead API
 type LeadAPI struct {
 	c *Client
@@ -76,16 +256,23 @@ func NewLeadAPI(c *Client) *LeadAPI {
 }
 
 // DescribeFields fetches the Lead schema from Marketo and returns the set of
-// attributes defined
+// attributes defined. If the Client is configured with a DescribeCacheTTL,
+// the cached schema is returned until it expires.
 func (l *LeadAPI) DescribeFields(ctx context.Context) ([]LeadAttribute2, error) {
-	request, err := http.NewRequest(
-		http.MethodGet, l.c.url("rest", "v1", "leads", "describe2.json"), nil,
+	// the cache is shared by every API using the Client, so callers are
+	// given copies which they may modify
+	if fields, ok := l.c.describe.get(leadDescribeKey); ok {
+		return copyLeadAttributes(fields.([]LeadAttribute2)), nil
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, l.c.restURL("leads", "describe2.json"), nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := l.c.doRequest(request)
+	resp, err := l.c.doRequest(describeLead2, request)
 	if err != nil {
 		return nil, err
 	}
@@ -118,59 +305,808 @@ func (l *LeadAPI) DescribeFields(ctx context.Context) ([]LeadAttribute2, error)
 		field.Searchable = searchable[field.Name]
 		object[0].Fields[i] = field
 	}
+
+	if err == nil {
+		l.c.describe.set(leadDescribeKey, copyLeadAttributes(object[0].Fields))
+	}
 	return object[0].Fields, err
 }
 
-// Filter queries Marketo for one or more Leads, returning them if present
+// ClearDescribeCache discards any cached describe results, forcing the next
+// call to DescribeFields to fetch the schema from Marketo.
+func (l *LeadAPI) ClearDescribeCache() {
+	l.c.describe.clear(leadDescribeKind)
+}
+
+// DescribeCacheStats returns the number of DescribeFields calls served from
+// the cache and fetched from Marketo.
+func (l *LeadAPI) DescribeCacheStats() DescribeCacheStats {
+	return l.c.describe.describeStats(leadDescribeKind)
+}
+
+// cachedFields returns the fields returned by the last call to
+// DescribeFields, or nil if DescribeFields has not been called.
+func (l *LeadAPI) cachedFields() []LeadAttribute2 {
+	if fields, ok := l.c.describe.last(leadDescribeKey); ok {
+		return fields.([]LeadAttribute2)
+	}
+	return nil
+}
+
+// GetByID fetches a single Lead by its Marketo ID. If fields are provided,
+// only those fields are returned.
+func (l *LeadAPI) GetByID(ctx context.Context, id int, fields ...string) (*LeadResult, error) {
+	path := l.c.restURL("lead", fmt.Sprintf("%d.json", id))
+	if len(fields) > 0 {
+		path += "?" + url.Values{"fields": {strings.Join(fields, ",")}}.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.c.doRequest(getLead, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(getLead, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, responseError(getLead, resp.StatusCode, response)
+	}
+
+	leads, err := decodeLeads(response.Result)
+	if err != nil {
+		return nil, err
+	}
+	if len(leads) == 0 {
+		return nil, errors.New("not found")
+	}
+	return &leads[0], nil
+}
+
+// GetByIDs fetches the Leads with the provided Marketo IDs, making as many
+// requests as needed, and returning the requested fields. Leads are returned
+// in the order of ids; IDs which do not match a Lead are omitted. See
+// FilterMulti for the handling of failed batches.
+func (l *LeadAPI) GetByIDs(ctx context.Context, ids []int, fields ...string) ([]LeadResult, error) {
+	return l.GetByIDsWith(ctx, ids, fieldOptions(fields)...)
+}
+
+// GetByIDsWith is GetByIDs with the options accepted by FilterMultiWith.
+func (l *LeadAPI) GetByIDsWith(ctx context.Context, ids []int, opts ...QueryOption) ([]LeadResult, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.Itoa(id)
+	}
+	leads, err := l.filterBatches(ctx, FilterTypeID, values, opts)
+
+	found := make(map[int]LeadResult, len(leads))
+	for _, lead := range leads {
+		found[lead.ID] = lead
+	}
+	leads = leads[:0]
+	for _, id := range ids {
+		if lead, ok := found[id]; ok {
+			leads = append(leads, lead)
+			delete(found, id)
+		}
+	}
+	return leads, err
+}
+
+// FilterMulti queries Marketo for the Leads matching any of values for field,
+// splitting values into batches of MaximumQueryBatchSize and fetching every
+// page of each batch, and returning the requested fields. Leads matched by
+// more than one value are returned once.
+//
+// Up to DefaultBatchConcurrency batches are requested at once; use
+// FilterMultiWith to set the Concurrency. If any batch fails, the Leads
+// already fetched, including those from the pages of a failed batch read
+// before the failure, are returned along with a BatchError containing the
+// error of each failed batch.
+func (l *LeadAPI) FilterMulti(ctx context.Context, field string, values []string, fields ...string) ([]LeadResult, error) {
+	return l.filterBatches(ctx, field, values, fieldOptions(fields))
+}
+
+// FilterMultiWith is FilterMulti with options: up to Concurrency batches are
+// requested at once, DefaultBatchConcurrency if unset, and opts may also set
+// the Fields to return and a Timeout for each request.
+func (l *LeadAPI) FilterMultiWith(ctx context.Context, field string, values []string, opts ...QueryOption) ([]LeadResult, error) {
+	return l.filterBatches(ctx, field, values, opts)
+}
+
+// fieldOptions returns the options requesting fields, if any
+func fieldOptions(fields []string) []QueryOption {
+	if len(fields) == 0 {
+		return nil
+	}
+	return []QueryOption{GetFields(fields...)}
+}
+
+// filterBatches fetches all pages of the Leads matching values, in batches of
+// MaximumQueryBatchSize values, returning each Lead once in the order of the
+// batches.
+func (l *LeadAPI) filterBatches(ctx context.Context, field string, values []string, opts []QueryOption) ([]LeadResult, error) {
+	q := &Query{}
+	for _, opt := range opts {
+		opt(q)
+	}
+	concurrency := q.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	batches := (len(values) + MaximumQueryBatchSize - 1) / MaximumQueryBatchSize
+	results := make([][]LeadResult, batches)
+	errs := make([]error, batches)
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i := 0; i < batches; i++ {
+		start := i * MaximumQueryBatchSize
+		end := start + MaximumQueryBatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+
+		batchOpts := append(append([]QueryOption{}, opts...), FilterField(field), FilterValues(values[start:end]))
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = l.filterBatch(ctx, batchOpts)
+		}(i)
+	}
+	wg.Wait()
+
+	leads := []LeadResult{}
+	seen := map[int]bool{}
+	for _, batch := range results {
+		for _, lead := range batch {
+			if !seen[lead.ID] {
+				seen[lead.ID] = true
+				leads = append(leads, lead)
+			}
+		}
+	}
+	return leads, batchError(errs)
+}
+
+// filterBatch fetches every page of the Leads matching opts; if a page fails
+// the Leads from the preceding pages are returned along with the error.
+func (l *LeadAPI) filterBatch(ctx context.Context, opts []QueryOption) ([]LeadResult, error) {
+	leads := []LeadResult{}
+	page := ""
+	for {
+		results, next, err := l.Filter(ctx, append(opts, GetPage(page))...)
+		if err != nil {
+			return leads, err
+		}
+		leads = append(leads, results...)
+		if next == "" || len(results) == 0 {
+			return leads, nil
+		}
+		page = next
+	}
+}
+
+// Filter queries Marketo for one or more Leads, returning them if present. If
+// the Client is configured with ValidateFilters, the filter field is validated
+// against the searchable fields before making the request. If the page token
+// has expired the returned error matches ErrStalePagingToken.
 func (l *LeadAPI) Filter(ctx context.Context, opts ...QueryOption) ([]LeadResult, string, error) {
+	response, err := l.FilterRaw(ctx, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(response.Errors) > 0 {
+		return nil, "", pagingTokenError(responseError(filterLeads, http.StatusOK, response))
+	}
+
+	leads, err := decodeLeads(response.Result)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return leads, response.NextPageToken, nil
+}
+
+// LeadIterator steps through the Leads created or changed since a point in
+// time, fetching additional pages as needed.
+type LeadIterator struct {
+	ctx        context.Context
+	leads      *LeadAPI
+	activities *ActivitiesAPI
+	fields     []string
+
+	token string
+	more  bool
+	page  []LeadResult
+	lead  LeadResult
+	seen  map[int]bool
+	err   error
+}
+
+// ChangedSince returns an iterator over the Leads created or changed since
+// the provided time. Changes are read from the New Lead and Change Data Value
+// activities, and each Lead is then fetched with the provided fields; a Lead
+// is returned once, even if it changed more than once. Iteration may be
+// resumed later by passing the iterator's Token to ChangedAfter.
+func (l *LeadAPI) ChangedSince(ctx context.Context, since time.Time, fields ...string) (*LeadIterator, error) {
+	token, err := NewActivitiesAPI(l.c).PagingToken(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	return l.ChangedAfter(ctx, token, fields...), nil
+}
+
+// ChangedAfter returns an iterator over the Leads created or changed after
+// the activities identified by the paging token.
+func (l *LeadAPI) ChangedAfter(ctx context.Context, token string, fields ...string) *LeadIterator {
+	return &LeadIterator{
+		ctx:        ctx,
+		leads:      l,
+		activities: NewActivitiesAPI(l.c),
+		fields:     fields,
+		token:      token,
+		more:       true,
+		seen:       map[int]bool{},
+	}
+}
+
+// Next advances the iterator to the next Lead, returning false when there
+// are no more Leads or an error has occurred. The Leads fetched before an
+// error, including those fetched by a partially failed GetByIDs, are returned
+// before Next returns false and Err reports the error.
+func (i *LeadIterator) Next() bool {
+	for len(i.page) == 0 {
+		if i.err != nil || !i.more {
+			return false
+		}
+		i.fetch()
+	}
+
+	i.lead, i.page = i.page[0], i.page[1:]
+	return true
+}
+
+// fetch reads the next page of activities and fetches the Leads they refer
+// to.
+func (i *LeadIterator) fetch() {
+	activities, response, err := i.activities.leadActivities(
+		i.ctx, i.token, ActivityNewLead, ActivityChangeDataValue,
+	)
+	if err != nil {
+		i.err = err
+		return
+	}
+
+	ids := []int{}
+	for _, a := range activities {
+		if !i.seen[a.LeadID] {
+			i.seen[a.LeadID] = true
+			ids = append(ids, a.LeadID)
+		}
+	}
+	if len(ids) > 0 {
+		i.page, err = i.leads.GetByIDs(i.ctx, ids, i.fields...)
+		if err != nil {
+			i.err = err
+			return
+		}
+	}
+
+	i.token = response.NextPageToken
+	i.more = response.MoreResult
+}
+
+// Lead returns the current Lead
+func (i *LeadIterator) Lead() LeadResult {
+	return i.lead
+}
+
+// Err returns the error, if any, which stopped iteration
+func (i *LeadIterator) Err() error {
+	return i.err
+}
+
+// Token returns the paging token following the activities read so far;
+// passing it to ChangedAfter resumes iteration.
+func (i *LeadIterator) Token() string {
+	return i.token
+}
+
+// LeadField is a single named field of a Lead
+type LeadField struct {
+	Name  string
+	Value string
+}
+
+// OrderedFields returns the fields of lead in the order they are returned by
+// DescribeFields, which is cached if the Client is configured with a
+// DescribeCacheTTL. The id is always included, along with any other fields
+// returned for the lead or which are non-empty; fields which are not in the
+// describe result follow, ordered by name.
+func (l *LeadAPI) OrderedFields(ctx context.Context, lead LeadResult) ([]LeadField, error) {
+	described, err := l.DescribeFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := lead.values()
+	ordered := make([]LeadField, 0, len(values))
+	for _, f := range described {
+		if v, ok := values[f.Name]; ok {
+			ordered = append(ordered, LeadField{Name: f.Name, Value: v})
+			delete(values, f.Name)
+		}
+	}
+
+	remaining := make([]string, 0, len(values))
+	for name := range values {
+		remaining = append(remaining, name)
+	}
+	sort.Strings(remaining)
+	for _, name := range remaining {
+		ordered = append(ordered, LeadField{Name: name, Value: values[name]})
+	}
+	return ordered, nil
+}
+
+// decodeLeads decodes a page of leads one record at a time, so that only a
+// single intermediate record is held in memory. Numbers are decoded as
+// json.Number to preserve the precision of large integers.
+func decodeLeads(result json.RawMessage) ([]LeadResult, error) {
+	leads := []LeadResult{}
+	if len(result) == 0 {
+		return leads, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(result))
+	dec.UseNumber()
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return leads, nil
+	}
+	if t != json.Delim('[') {
+		return nil, fmt.Errorf("unexpected lead result: %v", t)
+	}
+
+	for dec.More() {
+		raw := map[string]interface{}{}
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		lead := LeadResult{}
+		if err := mapstructure.Decode(raw, &lead); err != nil {
+			return nil, err
+		}
+		leads = append(leads, lead)
+	}
+
+	return leads, nil
+}
+
+// FilterRaw queries Marketo for one or more Leads, returning the complete
+// response envelope. The Result is left undecoded; callers which need the
+// RequestID or Warnings should use this in place of Filter.
+func (l *LeadAPI) FilterRaw(ctx context.Context, opts ...QueryOption) (*Response, error) {
 	q := &Query{}
 	for _, opt := range opts {
 		opt(q)
 	}
+	ctx, cancel := withTimeout(ctx, q.Timeout)
+	defer cancel()
+
+	integer := q.FilterField == FilterTypeID
+	if l.c.validateFilters || q.AllFields {
+		fields, err := l.DescribeFields(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if q.AllFields {
+			q.Fields = FieldNames(fields)
+		}
+		if l.c.validateFilters {
+			if err := validateSearchable("filter", q.FilterField, fields); err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				if f.Name == q.FilterField && f.DataType.GoType() == intType {
+					integer = true
+				}
+			}
+		}
+	}
+	if integer {
+		if err := validateIntegerValues(q.FilterField, q.FilterValues); err != nil {
+			return nil, err
+		}
+	}
 
 	query, err := q.Values()
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	request, err := filterRequest(ctx, l.c.restURL("leads.json"), query, q.PreferGET)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.c.doRequest(filterLeads, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(filterLeads, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// CreateOrUpdate syncs up to MaximumSyncBatchSize leads to Marketo, returning
+// the result for each. If DescribeFields has been called, the lookup field is
+// validated against the searchable fields before making the request. With
+// the DryRun option the request is validated but not sent, and no results are
+// returned.
+func (l *LeadAPI) CreateOrUpdate(ctx context.Context, leads []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error) {
+	sr := &SyncRequest{Input: leads}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	ctx, cancel := withTimeout(ctx, sr.Timeout)
+	defer cancel()
+
+	if len(sr.Input) > MaximumSyncBatchSize {
+		return nil, errors.New("too many leads")
+	}
+	fields := l.cachedFields()
+	if sr.LookupField != "" && fields != nil {
+		if err := validateSearchable("lookup", sr.LookupField, fields); err != nil {
+			return nil, err
+		}
+	}
+	input, err := syncBooleans(sr.Input, fields)
+	if err != nil {
+		return nil, err
+	}
+	sr.Input = input
+	if sr.DryRun {
+		fields, err := l.DescribeFields(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if sr.Input, err = syncBooleans(sr.Input, fields); err != nil {
+			return nil, err
+		}
+		if err := validateSync(sr, fields); err != nil {
+			return nil, err
+		}
+		if sr.dryRun != nil {
+			*sr.dryRun = *sr
+			sr.dryRun.dryRun = nil
+		}
+		return nil, nil
+	}
+
+	body, err := json.Marshal(sr)
+	if err != nil {
+		return nil, err
+	}
+	return l.post(ctx, syncLeads, "leads.json", body)
+}
+
+// Associate associates the anonymous web activity tracked by a Munchkin
+// cookie with the Lead, so that the activity is recorded against it. cookie is
+// the value of the _mkto_trk cookie, ie
+// "id:123-ABC-456&token:_mch-example.com-1427205775289-40768". If the Lead
+// does not exist the returned error matches ErrLeadNotFound; if the cookie is
+// rejected it matches ErrInvalidCookie.
+func (l *LeadAPI) Associate(ctx context.Context, leadID int, cookie string) error {
+	if cookie == "" {
+		return errors.New("cookie is required")
+	}
+	path := l.c.restURL("leads", strconv.Itoa(leadID), "associate.json") +
+		"?" + url.Values{"cookie": {cookie}}.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := l.c.doRequest(associateLead, request)
+	if err != nil {
+		return err
 	}
-	request, err := http.NewRequest(
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return handleError(associateLead, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return responseError(associateLead, resp.StatusCode, response)
+	}
+	return nil
+}
+
+// Delete deletes the Leads with the provided Marketo IDs, in batches of
+// MaximumSyncBatchSize, and returns the result for each. If a batch fails the
+// results of the preceding batches are returned along with the error.
+func (l *LeadAPI) Delete(ctx context.Context, ids []int) ([]RecordResult, error) {
+	results := []RecordResult{}
+	for start := 0; start < len(ids); start += MaximumSyncBatchSize {
+		end := start + MaximumSyncBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		input := make([]map[string]int, 0, end-start)
+		for _, id := range ids[start:end] {
+			input = append(input, map[string]int{FilterTypeID: id})
+		}
+		body, err := json.Marshal(map[string]interface{}{"input": input})
+		if err != nil {
+			return results, err
+		}
+		batch, err := l.post(ctx, deleteLeads, "leads/delete.json", body)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+// DeleteBy deletes the Leads matching any of values for field, such as
+// "email", and returns the result for each. Marketo only deletes Leads by ID,
+// so unless field is "id" the matching Leads are first found with
+// FilterMulti: every Lead matching a value is deleted, and values which do not
+// match a Lead are omitted from the results.
+func (l *LeadAPI) DeleteBy(ctx context.Context, field string, values []string) ([]RecordResult, error) {
+	if field == "" {
+		return nil, errors.New("field is required")
+	}
+	ids := make([]int, 0, len(values))
+	if field == FilterTypeID {
+		if err := validateIntegerValues(field, values); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			id, _ := strconv.Atoi(v)
+			ids = append(ids, id)
+		}
+	} else {
+		leads, err := l.FilterMulti(ctx, field, values, FilterTypeID)
+		if err != nil {
+			return nil, err
+		}
+		for _, lead := range leads {
+			ids = append(ids, lead.ID)
+		}
+	}
+	return l.Delete(ctx, ids)
+}
+
+// pushRequest is the payload used to push Leads
+type pushRequest struct {
+	ProgramName   string                   `json:"programName"`
+	ProgramStatus string                   `json:"programStatus,omitempty"`
+	LookupField   string                   `json:"lookupField,omitempty"`
+	PartitionName string                   `json:"partitionName,omitempty"`
+	Source        string                   `json:"source,omitempty"`
+	Reason        string                   `json:"reason,omitempty"`
+	Input         []map[string]interface{} `json:"input"`
+}
+
+// Push upserts up to MaximumSyncBatchSize leads with Marketo's push endpoint,
+// adding them to the program with programName, and returns the result for
+// each. Unlike CreateOrUpdate, a pushed Lead triggers the smart campaigns
+// which listen for Leads being created or added to the program, as Leads
+// captured by a form do. The LookupField, PartitionName, PushProgramStatus,
+// PushSource, PushReason and SyncTimeout options are supported.
+func (l *LeadAPI) Push(ctx context.Context, programName string, leads []map[string]interface{}, opts ...SyncOption) ([]RecordResult, error) {
+	sr := &SyncRequest{Input: leads}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	ctx, cancel := withTimeout(ctx, sr.Timeout)
+	defer cancel()
+
+	if programName == "" {
+		return nil, errors.New("program name is required")
+	}
+	if len(sr.Input) > MaximumSyncBatchSize {
+		return nil, errors.New("too many leads")
+	}
+	if sr.Action != "" || sr.DryRun {
+		return nil, errors.New("push does not support the Action or DryRun options")
+	}
+	input, err := syncBooleans(sr.Input, l.cachedFields())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(pushRequest{
+		ProgramName:   programName,
+		ProgramStatus: sr.ProgramStatus,
+		LookupField:   sr.LookupField,
+		PartitionName: sr.PartitionName,
+		Source:        sr.Source,
+		Reason:        sr.Reason,
+		Input:         input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l.post(ctx, pushLeads, "leads/push.json", body)
+}
+
+// post sends body to the REST API path, returning the result for each record
+func (l *LeadAPI) post(ctx context.Context, operation, path string, body []byte) ([]RecordResult, error) {
+	request, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
-		l.c.url("rest", "v1", "leads.json?_method=GET"),
-		strings.NewReader(query.Encode()),
+		l.c.restURL(path),
+		bytes.NewReader(body),
 	)
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
+	request.Header.Add("Content-Type", "application/json")
 
-	resp, err := l.c.doRequest(request)
+	resp, err := l.c.doRequest(operation, request)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", handleError(filterLeads, resp)
+		return nil, handleError(operation, resp)
 	}
 
 	response := &Response{}
 	reader := json.NewDecoder(resp.Body)
 	err = reader.Decode(response)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, responseError(operation, resp.StatusCode, response)
 	}
 
-	raw := []map[string]interface{}{}
-	err = json.Unmarshal(response.Result, &raw)
+	results := []RecordResult{}
+	err = json.Unmarshal(response.Result, &results)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	leads := make([]LeadResult, len(raw))
-	for i, l := range raw {
-		err = mapstructure.Decode(l, &leads[i])
-		if err != nil {
-			return nil, "", err
+	return results, nil
+}
+
+// validateSync returns an error if the records in sr contain unknown fields
+// or are missing the lookup field.
+func validateSync(sr *SyncRequest, fields []LeadAttribute2) error {
+	lookup := sr.LookupField
+	if lookup == "" {
+		lookup = "email"
+	}
+	if err := validateSearchable("lookup", lookup, fields); err != nil {
+		return err
+	}
+	requireLookup := sr.Action != ActionCreateOnly && sr.Action != ActionCreateDuplicate
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+	}
+	for i, record := range sr.Input {
+		for name := range record {
+			if !known[name] {
+				return fmt.Errorf("record %d: unknown field %q", i, name)
+			}
+		}
+		if _, ok := record[lookup]; requireLookup && !ok {
+			return fmt.Errorf("record %d: missing lookup field %q", i, lookup)
 		}
 	}
+	return nil
+}
 
-	return leads, response.NextPageToken, nil
+// syncBooleans returns input with the string values of boolean fields, such
+// as "false", converted to booleans; Marketo treats any string as true. The
+// boolean fields are the standard fields in booleanLeadFields along with any
+// described as boolean in fields. Records are copied before they are
+// modified.
+func syncBooleans(input []map[string]interface{}, fields []LeadAttribute2) ([]map[string]interface{}, error) {
+	boolean := make(map[string]bool, len(booleanLeadFields))
+	for name := range booleanLeadFields {
+		boolean[name] = true
+	}
+	for _, f := range fields {
+		if f.DataType == DataTypeBoolean {
+			boolean[f.Name] = true
+		}
+	}
+
+	var result []map[string]interface{}
+	for i, record := range input {
+		var converted map[string]interface{}
+		for name, value := range record {
+			s, ok := value.(string)
+			if !ok || !boolean[name] {
+				continue
+			}
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("record %d: field %q must be a boolean, got %q", i, name, s)
+			}
+			if converted == nil {
+				converted = make(map[string]interface{}, len(record))
+				for k, v := range record {
+					converted[k] = v
+				}
+			}
+			converted[name] = b
+		}
+		if converted == nil {
+			continue
+		}
+		if result == nil {
+			result = append([]map[string]interface{}{}, input...)
+		}
+		result[i] = converted
+	}
+	if result == nil {
+		return input, nil
+	}
+	return result, nil
+}
+
+// validateSearchable returns an error if field is not one of the searchable
+// lead fields; use describes what the field is being used for.
+func validateSearchable(use, field string, fields []LeadAttribute2) error {
+	searchable := []string{}
+	for _, f := range fields {
+		if !f.Searchable {
+			continue
+		}
+		if f.Name == field {
+			return nil
+		}
+		searchable = append(searchable, f.Name)
+	}
+
+	return fmt.Errorf(
+		"invalid %s field %q: must be one of %s",
+		use, field, strings.Join(searchable, ", "),
+	)
 }
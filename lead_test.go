@@ -2,9 +2,21 @@ package marketo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
+	"github.com/polytomic/go-marketo/marketotest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
@@ -46,6 +58,39 @@ func TestLeadDescribe(t *testing.T) {
 		assert.True(t, passed, "could not find email field")
 	})
 
+	t.Run("field names", func(t *testing.T) {
+		names := FieldNames(fields)
+		assert.Len(t, names, 90)
+		assert.Contains(t, names, "salutation")
+		assert.Contains(t, SearchableFieldNames(fields), "email")
+		assert.NotContains(t, SearchableFieldNames(fields), "salutation")
+		assert.Contains(t, UpdateableFieldNames(fields), "salutation")
+		assert.NotContains(t, UpdateableFieldNames(fields), "createdAt")
+	})
+
+	t.Run("writable fields", func(t *testing.T) {
+		assert.Equal(t, UpdateableFieldNames(fields), WritableFieldNames(fields))
+
+		fields := []LeadAttribute2{
+			{Name: "email", Updateable: true},
+			{Name: "createdAt"},
+			{Name: "sfdcAccountId", Updateable: true, CRMManaged: true},
+		}
+		assert.Equal(t, []LeadAttribute2{fields[0]}, WritableFields(fields))
+		assert.Equal(t, []string{"email"}, WritableFieldNames(fields))
+	})
+
+	t.Run("includes picklist values", func(t *testing.T) {
+		for _, f := range fields {
+			switch f.Name {
+			case "salutation":
+				assert.Equal(t, []string{"Mr.", "Ms.", "Dr."}, f.Values)
+			case "email":
+				assert.Nil(t, f.Values)
+			}
+		}
+	})
+
 	assert.True(t, gock.IsDone())
 }
 
@@ -86,6 +131,221 @@ func TestFilterLeads(t *testing.T) {
 	assert.True(t, gock.IsDone())
 }
 
+func TestFilterLeads_paging(t *testing.T) {
+	defer gock.Off()
+
+	bodies := marketotest.MultiPageResponse(t, [][]map[string]interface{}{
+		{{"id": 1, "email": "nathan@polytomic.com"}},
+		{{"id": 2, "email": "ghalib@polytomic.com"}},
+	})
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	for i, body := range bodies {
+		token := ""
+		if i > 0 {
+			token = marketotest.PageToken(i)
+		}
+		gock.New(testHost).
+			Post("/rest/v1/leads.json").
+			AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+				require.NoError(t, r.ParseForm())
+				return r.PostForm.Get("nextPageToken") == token, nil
+			}).
+			Reply(http.StatusOK).
+			JSON(body)
+	}
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	ids := []int{}
+	token := ""
+	for {
+		leads, next, err := api.Filter(
+			context.Background(),
+			FilterField("email"),
+			FilterValues([]string{"nathan@polytomic.com", "ghalib@polytomic.com"}),
+			GetPage(token),
+		)
+		require.NoError(t, err)
+		for _, l := range leads {
+			ids = append(ids, l.ID)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	assert.Equal(t, []int{1, 2}, ids)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_byID(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "id", r.PostForm.Get("filterType"))
+			assert.Equal(t, "1,2", r.PostForm.Get("filterValues"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, _, err = NewLeadAPI(client).Filter(context.Background(), FilterByID(1, 2))
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_invalidIDs(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, _, err = NewLeadAPI(client).Filter(
+		context.Background(),
+		FilterField(FilterTypeID),
+		FilterValues([]string{"1", "9999999999", "abc"}),
+	)
+	assert.EqualError(t, err, "invalid id filter values: 9999999999, abc must be integers")
+	assert.True(t, gock.IsDone())
+}
+
+func TestGetLeadsByIDs(t *testing.T) {
+	defer gock.Off()
+
+	ids := []int{1000049, 1000048}
+	for id := 1; len(ids) <= MaximumQueryBatchSize; id++ {
+		ids = append(ids, id)
+	}
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			values := strings.Split(r.PostForm.Get("filterValues"), ",")
+			return len(values) == MaximumQueryBatchSize && values[0] == "1000049", nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "id", r.PostForm.Get("filterType"))
+			assert.Equal(t, "email", r.PostForm.Get("fields"))
+			return r.PostForm.Get("filterValues") == strconv.Itoa(ids[MaximumQueryBatchSize]), nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	leads, err := NewLeadAPI(client).GetByIDs(context.Background(), ids, "email")
+	require.NoError(t, err)
+
+	require.Len(t, leads, 2)
+	assert.Equal(t, 1000049, leads[0].ID)
+	assert.Equal(t, 1000048, leads[1].ID)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterMulti(t *testing.T) {
+	defer gock.Off()
+
+	emails := make([]string, MaximumQueryBatchSize+1)
+	for i := range emails {
+		emails[i] = fmt.Sprintf("lead%d@example.com", i)
+	}
+	batchSize := func(n int) gock.MatchFunc {
+		return func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			return len(strings.Split(r.PostForm.Get("filterValues"), ",")) == n, nil
+		}
+	}
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(batchSize(MaximumQueryBatchSize)).
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			return r.PostForm.Get("nextPageToken") == "", nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"nextPageToken":"PAGE2","result":[{"id":1,"email":"lead0@example.com"}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(batchSize(MaximumQueryBatchSize)).
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			return r.PostForm.Get("nextPageToken") == "PAGE2", nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[{"id":2,"email":"lead1@example.com"}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(batchSize(1)).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"3","success":true,"result":[{"id":1,"email":"lead300@example.com"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	leads, err := NewLeadAPI(client).FilterMulti(context.Background(), "email", emails, "email")
+	require.NoError(t, err)
+
+	require.Len(t, leads, 2)
+	assert.Equal(t, 1, leads[0].ID)
+	assert.Equal(t, 2, leads[1].ID)
+	assert.True(t, gock.IsDone())
+}
+
 func TestFilterLeads_withFields(t *testing.T) {
 	defer gock.Off()
 
@@ -126,3 +386,1089 @@ func TestFilterLeads_withFields(t *testing.T) {
 
 	assert.True(t, gock.IsDone())
 }
+
+func TestFilterLeadsRaw(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+		Debug:    true,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	response, err := api.FilterRaw(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"nathan@polytomic.com", "ghalib@polytomic.com"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1771c#176fe6daa75", response.RequestID)
+	assert.True(t, response.Success)
+	assert.NotEmpty(t, response.Result)
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreateOrUpdateLeads(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"action":      "createOrUpdate",
+			"lookupField": "externalCompanyId",
+			"input": []map[string]interface{}{
+				{"email": "nathan@polytomic.com", "externalCompanyId": "1234"},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"e42b#14272d07d78","success":true,"result":[{"id":50,"status":"created"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+		Debug:    true,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	results, err := api.CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{
+			{"email": "nathan@polytomic.com", "externalCompanyId": "1234"},
+		},
+		Action(ActionCreateOrUpdate),
+		LookupField("externalCompanyId"),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, RecordResult{ID: 50, Status: "created"}, results[0])
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreateOrUpdateLeads_partition(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"partitionName": "Europe",
+			"input": []map[string]interface{}{
+				{"email": "nathan@polytomic.com"},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"e42b#14272d07d78","success":true,"result":[{"status":"skipped","reasons":[{"code":"1008","message":"Access denied to partition 'Europe'"}]}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	results, err := NewLeadAPI(client).CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{{"email": "nathan@polytomic.com"}},
+		PartitionName("Europe"),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, results, 1)
+	assert.True(t, errors.Is(results[0].Err(), ErrPartitionAccessDenied))
+	assert.Zero(t, results[0].Err().(Error).StatusCode)
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreateOrUpdateLeads_dryRun(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	sent := SyncRequest{}
+	results, err := api.CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{{"email": "nathan@polytomic.com", "firstName": "Nathan"}},
+		Action(ActionUpdateOnly), DryRun(&sent),
+	)
+	require.NoError(t, err)
+	assert.Nil(t, results)
+	assert.Equal(t, ActionUpdateOnly, sent.Action)
+	assert.Len(t, sent.Input, 1)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{{"email": "nathan@polytomic.com", "nickname": "Nate"}},
+		DryRun(nil),
+	)
+	assert.EqualError(t, err, `record 0: unknown field "nickname"`)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{{"firstName": "Nathan"}},
+		DryRun(nil),
+	)
+	assert.EqualError(t, err, `record 0: missing lookup field "email"`)
+
+	// no sync request is made
+	assert.True(t, gock.IsDone())
+}
+
+func TestCreateOrUpdateLeads_invalidLookupField(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+		Debug:    true,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	_, err = api.DescribeFields(context.Background())
+	require.NoError(t, err)
+
+	_, err = api.CreateOrUpdate(
+		context.Background(),
+		[]map[string]interface{}{{"email": "nathan@polytomic.com"}},
+		LookupField("firstName"),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid lookup field "firstName"`)
+	assert.Contains(t, err.Error(), "email")
+	assert.True(t, gock.IsDone())
+}
+
+func TestLeadDescribe_cached(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Times(2).
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         "https://marketo.testing",
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	for i := 0; i < 3; i++ {
+		fields, err := api.DescribeFields(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, fields, 90)
+	}
+	assert.False(t, gock.IsDone(), "expected describe to be served from cache")
+	assert.Equal(t, DescribeCacheStats{Hits: 2, Misses: 1}, api.DescribeCacheStats())
+
+	api.ClearDescribeCache()
+	_, err = api.DescribeFields(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, DescribeCacheStats{Hits: 2, Misses: 2}, api.DescribeCacheStats())
+	assert.True(t, gock.IsDone())
+}
+
+func TestLeadDescribe_sharedCache(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	_, err = NewLeadAPI(client).DescribeFields(context.Background())
+	require.NoError(t, err)
+
+	// clearing the custom object descriptions leaves the Lead schema cached
+	NewCustomObjectsAPI(client).ClearDescribeCache()
+	api := NewLeadAPI(client)
+	fields, err := api.DescribeFields(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, fields, 90)
+	assert.Equal(t, DescribeCacheStats{Hits: 1, Misses: 1}, api.DescribeCacheStats())
+	assert.Equal(t, DescribeCacheStats{}, NewCustomObjectsAPI(client).DescribeCacheStats())
+
+	// changes to a description do not affect the cached copy
+	name := fields[0].Name
+	fields[0].Name = "changed"
+	fields, err = NewLeadAPI(client).DescribeFields(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, name, fields[0].Name)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDecodeLeads(t *testing.T) {
+	leads, err := decodeLeads(json.RawMessage(
+		`[{"id":1,"email":"nathan@polytomic.com","company":"Polytomic"},{"id":2}]`,
+	))
+	require.NoError(t, err)
+	require.Len(t, leads, 2)
+	assert.Equal(t, 1, leads[0].ID)
+	assert.Equal(t, "nathan@polytomic.com", leads[0].Email)
+	assert.Equal(t, "Polytomic", leads[0].Fields["company"])
+	assert.Equal(t, 2, leads[1].ID)
+
+	leads, err = decodeLeads(nil)
+	require.NoError(t, err)
+	assert.Empty(t, leads)
+
+	_, err = decodeLeads(json.RawMessage(`{"id":1}`))
+	assert.Error(t, err)
+}
+
+func TestLeadResultJSON(t *testing.T) {
+	leads, err := decodeLeads(json.RawMessage(
+		`[{"id":1,"email":"nathan@polytomic.com","firstName":"Nathan","createdAt":"2021-01-13T00:01:58Z","company":"Polytomic","title":"CTO"}]`,
+	))
+	require.NoError(t, err)
+	require.Len(t, leads, 1)
+
+	encoded, err := json.Marshal(leads[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"company":"Polytomic"`)
+
+	decoded := LeadResult{}
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, leads[0], decoded)
+}
+
+// leadPage returns a page of MaximumQueryBatchSize leads with many fields
+func leadPage(b *testing.B) json.RawMessage {
+	records := make([]map[string]interface{}, MaximumQueryBatchSize)
+	for i := range records {
+		record := map[string]interface{}{
+			"id":    i,
+			"email": fmt.Sprintf("lead%d@example.com", i),
+		}
+		for f := 0; f < 100; f++ {
+			record[fmt.Sprintf("field%d", f)] = fmt.Sprintf("value %d", f)
+		}
+		records[i] = record
+	}
+
+	page, err := json.Marshal(records)
+	require.NoError(b, err)
+	return page
+}
+
+func BenchmarkDecodeLeads(b *testing.B) {
+	page := leadPage(b)
+
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			raw := []map[string]interface{}{}
+			require.NoError(b, json.Unmarshal(page, &raw))
+			leads := make([]LeadResult, len(raw))
+			for i, l := range raw {
+				require.NoError(b, mapstructure.Decode(l, &leads[i]))
+			}
+		}
+	})
+
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			_, err := decodeLeads(page)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func TestDecodeLeads_largeNumbers(t *testing.T) {
+	leads, err := decodeLeads(json.RawMessage(`[{"id":1,"externalId":1234567890123456789}]`))
+	require.NoError(t, err)
+	require.Len(t, leads, 1)
+	assert.Equal(t, "1234567890123456789", leads[0].Fields["externalId"])
+}
+
+func TestLeadDescribe_expiredToken(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		JSON(tokenExpiredResponse)
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+	})
+	require.NoError(t, err)
+
+	fields, err := NewLeadAPI(client).DescribeFields(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, fields, 90)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_invalidToken(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(invalidTokenResponse)
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			// the form body is sent again with the retry
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "email", r.PostForm.Get("filterType"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+	})
+	require.NoError(t, err)
+
+	leads, _, err := NewLeadAPI(client).Filter(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"nathan@polytomic.com", "ghalib@polytomic.com"}),
+	)
+	require.NoError(t, err)
+	assert.Len(t, leads, 2)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_validatesField(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         "https://marketo.testing",
+		DescribeCacheTTL: time.Hour,
+		ValidateFilters:  true,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	_, _, err = api.Filter(
+		context.Background(),
+		FilterField("firstName"),
+		FilterValues([]string{"Nathan"}),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid filter field "firstName": must be one of`)
+
+	// the cached describe is used to validate subsequent filters
+	leads, _, err := api.Filter(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"nathan@polytomic.com", "ghalib@polytomic.com"}),
+	)
+	require.NoError(t, err)
+	assert.Len(t, leads, 2)
+	assert.True(t, gock.IsDone())
+}
+
+func TestGetLeadByID(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/lead/318581.json").
+		MatchParam("fields", "email,company").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"id":318581,"email":"nathan@polytomic.com","company":"Polytomic"}]}`)
+	gock.New(testHost).
+		Get("/rest/v1/lead/1.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: "https://marketo.testing",
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	lead, err := api.GetByID(context.Background(), 318581, "email", "company")
+	require.NoError(t, err)
+	assert.Equal(t, 318581, lead.ID)
+	assert.Equal(t, "Polytomic", lead.Fields["company"])
+
+	_, err = api.GetByID(context.Background(), 1)
+	assert.EqualError(t, err, "not found")
+	assert.True(t, gock.IsDone())
+}
+
+func TestLeadsChangedSince(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/activities/pagingtoken.json").
+		MatchParam("sinceDatetime", "2021-01-13T00:00:00Z").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"nextPageToken":"PAGE1"}`)
+	gock.New(testHost).
+		Get("/rest/v1/activities.json").
+		MatchParam("nextPageToken", "PAGE1").
+		MatchParam("activityTypeIds", "12,13").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"nextPageToken":"PAGE2","moreResult":true,"result":[
+			{"id":1,"leadId":1000048,"activityDate":"2021-01-13T00:01:58Z","activityTypeId":12},
+			{"id":2,"leadId":1000048,"activityDate":"2021-01-13T00:01:59Z","activityTypeId":13},
+			{"id":3,"leadId":1000049,"activityDate":"2021-01-13T00:01:59Z","activityTypeId":13}
+		]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "1000048,1000049", r.PostForm.Get("filterValues"))
+			return true, nil
+		}).
+		Reply(http.StatusOK).
+		File("test-fixtures/filterLeads.json")
+	gock.New(testHost).
+		Get("/rest/v1/activities.json").
+		MatchParam("nextPageToken", "PAGE2").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"3","success":true,"nextPageToken":"PAGE3","moreResult":false,"result":[
+			{"id":4,"leadId":1000049,"activityDate":"2021-01-13T00:02:00Z","activityTypeId":13}
+		]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	it, err := NewLeadAPI(client).ChangedSince(
+		context.Background(), time.Date(2021, 1, 13, 0, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+
+	ids := []int{}
+	for it.Next() {
+		ids = append(ids, it.Lead().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int{1000048, 1000049}, ids)
+	assert.Equal(t, "PAGE3", it.Token())
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_withTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(authResponseSuccess))
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: ts.URL,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	start := time.Now()
+	_, _, err = api.Filter(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"tester@example.com"}),
+		WithTimeout(20*time.Millisecond),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+
+	// an earlier deadline on the context takes precedence
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = api.CreateOrUpdate(
+		ctx,
+		[]map[string]interface{}{{"email": "tester@example.com"}},
+		SyncTimeout(time.Hour),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+}
+
+func TestOrderedFields(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	fields, err := NewLeadAPI(client).OrderedFields(context.Background(), LeadResult{
+		ID:    1,
+		Email: "tester@example.com",
+		Fields: map[string]string{
+			"city":        "Richmond",
+			"address":     "1 Main St",
+			"customField": "custom",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []LeadField{
+		{Name: "address", Value: "1 Main St"},
+		{Name: "city", Value: "Richmond"},
+		{Name: "email", Value: "tester@example.com"},
+		{Name: "id", Value: "1"},
+		{Name: "customField", Value: "custom"},
+	}, fields)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterMulti_concurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/identity/oauth/token" {
+			w.Write([]byte(authResponseSuccess))
+			return
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		require.NoError(t, r.ParseForm())
+		first := strings.Split(r.PostForm.Get("filterValues"), ",")[0]
+		if first == "300" {
+			w.Write([]byte(`{"requestId":"e","success":false,"errors":[{"code":"1003","message":"Invalid data"}]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"requestId":"r","success":true,"result":[{"id":%s}]}`, first)
+	}))
+	defer ts.Close()
+
+	ids := make([]int, 5*MaximumQueryBatchSize)
+	for i := range ids {
+		ids[i] = i
+	}
+
+	for _, tc := range []struct {
+		name        string
+		maxRequests int
+		concurrency int
+		expected    int32
+	}{
+		{"batch concurrency", 0, 2, 2},
+		{"client limit", 1, 4, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&maxInFlight, 0)
+			client, err := NewClient(ClientConfig{
+				ID:                    clientID,
+				Secret:                clientSecret,
+				Endpoint:              ts.URL,
+				MaxConcurrentRequests: tc.maxRequests,
+			})
+			require.NoError(t, err)
+
+			leads, err := NewLeadAPI(client).GetByIDsWith(context.Background(), ids, Concurrency(tc.concurrency))
+			assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), tc.expected)
+
+			// the leads from the successful batches are returned in order
+			// with the failed batch's error
+			require.Len(t, leads, 4)
+			for i, id := range []int{0, 600, 900, 1200} {
+				assert.Equal(t, id, leads[i].ID)
+			}
+			var bErr BatchError
+			require.True(t, errors.As(err, &bErr), err)
+			assert.Len(t, bErr.Errors, 1)
+			assert.True(t, errors.Is(err, Reason{Code: "1003"}))
+		})
+	}
+}
+
+func TestCreateOrUpdateLeads_booleans(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return false, err
+			}
+			return assert.JSONEq(t, `{"input":[
+				{"email":"tester@example.com","unsubscribed":false,"marketingSuspended":false},
+				{"email":"other@example.com","unsubscribed":true,"unsubscribedReason":"false"}
+			]}`, string(body)), nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"e42b#14272d07d78","success":true,"result":[{"id":50,"status":"updated"},{"id":51,"status":"updated"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	leads := []map[string]interface{}{
+		{"email": "tester@example.com", LeadFieldUnsubscribed: false, LeadFieldMarketingSuspended: "false"},
+		{"email": "other@example.com", LeadFieldUnsubscribed: "TRUE", LeadFieldUnsubscribedReason: "false"},
+	}
+	_, err = api.CreateOrUpdate(context.Background(), leads)
+	require.NoError(t, err)
+	assert.Equal(t, "false", leads[0][LeadFieldMarketingSuspended], "the caller's records are not modified")
+
+	_, err = api.CreateOrUpdate(context.Background(), []map[string]interface{}{
+		{"email": "tester@example.com", LeadFieldUnsubscribed: "no thanks"},
+	})
+	assert.EqualError(t, err, `record 0: field "unsubscribed" must be a boolean, got "no thanks"`)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterMulti_partialPages(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			return r.PostForm.Get("nextPageToken") == "", nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"nextPageToken":"PAGE2","result":[{"id":1,"email":"lead0@example.com"}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":false,"errors":[{"code":"604","message":"Request timed out"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	leads, err := NewLeadAPI(client).FilterMulti(
+		context.Background(), "email", []string{"lead0@example.com", "lead1@example.com"},
+	)
+	assert.True(t, errors.Is(err, ErrRequestTimeOut), err)
+	require.Len(t, leads, 1)
+	assert.Equal(t, 1, leads[0].ID)
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_allFields(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			fields := strings.Split(r.PostForm.Get("fields"), ",")
+			return len(fields) == 90 && fields[0] != "" && r.URL.Query().Get("fields") == "", nil
+		}).
+		Times(2).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"id":1,"email":"tester@example.com"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:               clientID,
+		Secret:           clientSecret,
+		Endpoint:         testHost,
+		DescribeCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	// the describe result is cached for the second query
+	for i := 0; i < 2; i++ {
+		leads, _, err := api.Filter(
+			context.Background(),
+			FilterField("email"),
+			FilterValues([]string{"tester@example.com"}),
+			AllFields(),
+		)
+		require.NoError(t, err)
+		require.Len(t, leads, 1)
+	}
+	assert.True(t, gock.IsDone())
+}
+
+func TestPushLeads(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads/push.json").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"programName":   "Inbound Web Leads",
+			"programStatus": "Registered",
+			"lookupField":   "email",
+			"partitionName": "Europe",
+			"source":        "Web Form",
+			"input": []map[string]interface{}{
+				{"email": "tester@example.com", "unsubscribed": false},
+			},
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"e42b#14272d07d78","success":true,"result":[{"id":50,"status":"created"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	results, err := api.Push(
+		context.Background(),
+		"Inbound Web Leads",
+		[]map[string]interface{}{
+			{"email": "tester@example.com", LeadFieldUnsubscribed: "false"},
+		},
+		LookupField("email"),
+		PartitionName("Europe"),
+		PushProgramStatus("Registered"),
+		PushSource("Web Form"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []RecordResult{{ID: 50, Status: RecordCreated}}, results)
+
+	_, err = api.Push(context.Background(), "", nil)
+	assert.EqualError(t, err, "program name is required")
+	_, err = api.Push(context.Background(), "Inbound Web Leads", nil, Action(ActionCreateOnly))
+	assert.Error(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestAssociateLead(t *testing.T) {
+	defer gock.Off()
+
+	cookie := "id:123-ABC-456&token:_mch-example.com-1427205775289-40768"
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads/50/associate.json").
+		MatchParam("cookie", regexp.QuoteMeta(cookie)).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads/51/associate.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":false,"errors":[{"code":"1004","message":"Lead '51' not found"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	require.NoError(t, api.Associate(context.Background(), 50, cookie))
+
+	err = api.Associate(context.Background(), 51, cookie)
+	assert.True(t, errors.Is(err, ErrLeadNotFound), err)
+	assert.EqualError(t, err, "associate lead: [1004] Lead '51' not found (status 200, requestId 2)")
+
+	assert.EqualError(t, api.Associate(context.Background(), 52, ""), "cookie is required")
+	assert.True(t, gock.IsDone())
+}
+
+func TestDeleteLeads(t *testing.T) {
+	defer gock.Off()
+
+	ids := make([]int, MaximumSyncBatchSize+1)
+	first := make([]map[string]interface{}, MaximumSyncBatchSize)
+	for i := range ids {
+		ids[i] = i + 1
+		if i < MaximumSyncBatchSize {
+			first[i] = map[string]interface{}{"id": i + 1}
+		}
+	}
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads/delete.json").
+		MatchType("json").
+		JSON(map[string]interface{}{"input": first}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"a","success":true,"result":[{"id":1,"status":"deleted"}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads/delete.json").
+		MatchType("json").
+		JSON(map[string]interface{}{"input": []map[string]interface{}{{"id": MaximumSyncBatchSize + 1}}}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"b","success":true,"result":[{"id":301,"status":"skipped","reasons":[{"code":"1004","message":"Lead not found"}]}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	results, err := NewLeadAPI(client).Delete(context.Background(), ids)
+	require.NoError(t, err)
+	assert.Equal(t, []RecordResult{
+		{ID: 1, Status: RecordDeleted},
+		{ID: 301, Status: RecordSkipped, Reasons: []Reason{{Code: "1004", Message: "Lead not found"}}},
+	}, results)
+	assert.True(t, gock.IsDone())
+}
+
+func TestDeleteLeadsBy(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		BodyString(`fields=id&filterType=email&filterValues=a%40example.com%2Cb%40example.com`).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"a","success":true,"result":[{"id":10},{"id":11}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads/delete.json").
+		MatchType("json").
+		JSON(map[string]interface{}{"input": []map[string]interface{}{{"id": 10}, {"id": 11}}}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"b","success":true,"result":[{"id":10,"status":"deleted"},{"id":11,"status":"deleted"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	results, err := api.DeleteBy(context.Background(), "email", []string{"a@example.com", "b@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []RecordResult{{ID: 10, Status: RecordDeleted}, {ID: 11, Status: RecordDeleted}}, results)
+
+	_, err = api.DeleteBy(context.Background(), FilterTypeID, []string{"abc"})
+	assert.EqualError(t, err, "invalid id filter values: abc must be integers")
+	assert.True(t, gock.IsDone())
+}
+
+func TestFilterLeads_preferGET(t *testing.T) {
+	defer gock.Off()
+
+	long := make([]string, MaximumQueryBatchSize)
+	for i := range long {
+		long[i] = fmt.Sprintf("a-rather-long-address-%d@example.com", i)
+	}
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		MatchParams(map[string]string{
+			"filterType":   "email",
+			"filterValues": "tester@example.com",
+			"batchSize":    "300",
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"id":1,"email":"tester@example.com"}]}`)
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		MatchParam("_method", "GET").
+		AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+			require.NoError(t, r.ParseForm())
+			return r.PostForm.Get("filterValues") == strings.Join(long, ","), nil
+		}).
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":true,"result":[{"id":2,"email":"a-rather-long-address-0@example.com"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewLeadAPI(client)
+
+	leads, _, err := api.Filter(context.Background(), FilterField("email"), FilterValues([]string{"tester@example.com"}), PreferGET())
+	require.NoError(t, err)
+	require.Len(t, leads, 1)
+	assert.Equal(t, 1, leads[0].ID)
+
+	// the URL would be too long, so the query is sent in the body
+	leads, _, err = api.Filter(context.Background(), FilterField("email"), FilterValues(long), PreferGET())
+	require.NoError(t, err)
+	require.Len(t, leads, 1)
+	assert.Equal(t, 2, leads[0].ID)
+	assert.True(t, gock.IsDone())
+}
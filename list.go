@@ -1,16 +1,37 @@
 package marketo
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	// FilterTypeID filters records by their Marketo ID
+	FilterTypeID = "id"
+	// FilterTypeGUID filters records by their Marketo GUID
+	FilterTypeGUID = "marketoGUID"
 )
 
 const (
 	// MaximumQueryBatchSize is the largest batch size requestable via Marketo's
 	// list/query API.
 	MaximumQueryBatchSize = 300
+
+	// DefaultBatchConcurrency is the number of batches a multi-batch query
+	// requests at once unless Concurrency is set
+	DefaultBatchConcurrency = 4
+
+	// MaximumGETURLLength is the longest URL a query with PreferGET set
+	// sends as a GET request; longer queries are sent in a POST body
+	MaximumGETURLLength = 8 << 10
 )
 
 // Query contains the possible parameters used when listing Marketo objects
@@ -20,14 +41,35 @@ type Query struct {
 	Fields        []string `json:"fields,omitempty"`
 	BatchSize     int      `json:"batchSize,omitempty"`
 	NextPageToken string   `json:"nextPageToken,omitempty"`
+
+	// Timeout limits the duration of the query, if set
+	Timeout time.Duration `json:"-"`
+	// Concurrency is the number of batches a multi-batch query, such as
+	// LeadAPI.FilterMultiWith, requests at once
+	Concurrency int `json:"-"`
+	// AllFields is set when every field, as returned by describe, should
+	// be requested in place of Fields
+	AllFields bool `json:"-"`
+	// RepeatFilterValues is set when each of FilterValues should be sent
+	// as a separate filterValues parameter in place of a comma separated
+	// list
+	RepeatFilterValues bool `json:"-"`
+	// PreferGET is set when the query should be sent in the URL of a GET
+	// request, rather than the body of a POST, if the URL is no longer
+	// than MaximumGETURLLength
+	PreferGET bool `json:"-"`
 }
 
 // Values returns the query payload as url.Values; if the query is invalid, an
-// error is returned.
+// error is returned. A zero BatchSize requests MaximumQueryBatchSize records;
+// the Query itself is not modified. FilterValues are sent as a comma
+// separated list unless RepeatFilterValues is set or a value contains a
+// comma, in which case each is sent as a separate filterValues parameter.
 func (q *Query) Values() (url.Values, error) {
 	result := url.Values{}
-	if q.BatchSize == 0 {
-		q.BatchSize = MaximumQueryBatchSize
+	batchSize := q.BatchSize
+	if batchSize == 0 {
+		batchSize = MaximumQueryBatchSize
 	}
 
 	if len(q.FilterValues) < 1 {
@@ -39,11 +81,17 @@ func (q *Query) Values() (url.Values, error) {
 
 	values := url.Values{}
 	values.Set("filterType", q.FilterField)
-	values.Set("filterValues", strings.Join(q.FilterValues, ","))
+	if q.RepeatFilterValues || containsComma(q.FilterValues) {
+		for _, v := range q.FilterValues {
+			values.Add("filterValues", v)
+		}
+	} else {
+		values.Set("filterValues", strings.Join(q.FilterValues, ","))
+	}
 	if len(q.Fields) > 0 {
 		values.Set("fields", strings.Join(q.Fields, ","))
 	}
-	values.Set("batchSize", strconv.Itoa(q.BatchSize))
+	values.Set("batchSize", strconv.Itoa(batchSize))
 	if q.NextPageToken != "" {
 		values.Set("nextPageToken", q.NextPageToken)
 	}
@@ -51,6 +99,57 @@ func (q *Query) Values() (url.Values, error) {
 	return values, nil
 }
 
+// filterRequest returns the request for the query of the REST API url. The
+// query is sent in the URL of a GET request if preferGET is set and the URL
+// is no longer than MaximumGETURLLength; otherwise it is sent as the form body
+// of a POST with the _method=GET parameter, which Marketo accepts in place of
+// a GET.
+func filterRequest(ctx context.Context, u string, query url.Values, preferGET bool) (*http.Request, error) {
+	encoded := query.Encode()
+	if get := u + "?" + encoded; preferGET && len(get) <= MaximumGETURLLength {
+		return http.NewRequestWithContext(ctx, http.MethodGet, get, nil)
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		u+"?_method=GET",
+		strings.NewReader(encoded),
+	)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return request, nil
+}
+
+// containsComma returns true if any of values contains a comma
+func containsComma(values []string) bool {
+	for _, v := range values {
+		if strings.Contains(v, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateIntegerValues returns an error listing the values of an integer
+// filter field which are not valid Marketo integers, which are 32 bits
+func validateIntegerValues(field string, values []string) error {
+	invalid := []string{}
+	for _, v := range values {
+		if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+			invalid = append(invalid, v)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf(
+			"invalid %s filter values: %s must be integers", field, strings.Join(invalid, ", "),
+		)
+	}
+	return nil
+}
+
 // QueryOption defines the signature of functional options for Marketo Query
 // APIs.
 type QueryOption func(*Query)
@@ -69,6 +168,67 @@ func FilterValues(values []string) QueryOption {
 	}
 }
 
+// FilterByID filters records by their Marketo ID
+func FilterByID(ids ...int) QueryOption {
+	return func(q *Query) {
+		q.FilterField = FilterTypeID
+		q.FilterValues = make([]string, len(ids))
+		for i, id := range ids {
+			q.FilterValues[i] = strconv.Itoa(id)
+		}
+	}
+}
+
+// FilterByGUID filters records by their Marketo GUID
+func FilterByGUID(guids ...string) QueryOption {
+	return func(q *Query) {
+		q.FilterField = FilterTypeGUID
+		q.FilterValues = guids
+	}
+}
+
+// EmailHash returns the hash of email used by FilterByEmailHash: the
+// lowercase hex encoded MD5 digest of the email address after surrounding
+// whitespace is removed and it is lower cased.
+func EmailHash(email string) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// FilterByEmailHash filters records by the EmailHash of emails, so that the
+// plaintext addresses are not sent to Marketo. Marketo does not provide a
+// hashed email filter type, so field must be a searchable field, such as a
+// custom lead field, which the instance populates with the EmailHash of each
+// record's email address.
+func FilterByEmailHash(field string, emails ...string) QueryOption {
+	return func(q *Query) {
+		q.FilterField = field
+		q.FilterValues = make([]string, len(emails))
+		for i, email := range emails {
+			q.FilterValues[i] = EmailHash(email)
+		}
+	}
+}
+
+// WithTimeout limits the duration of the query, including any describe
+// request it requires, without constructing a deadlined context. If the
+// context passed to the query has an earlier deadline, that is used instead.
+func WithTimeout(d time.Duration) QueryOption {
+	return func(q *Query) {
+		q.Timeout = d
+	}
+}
+
+// Concurrency sets the number of batches a multi-batch query, such as
+// LeadAPI.FilterMultiWith, requests at once; the default is
+// DefaultBatchConcurrency. Requests are also limited by the Client's
+// MaxConcurrentRequests.
+func Concurrency(n int) QueryOption {
+	return func(q *Query) {
+		q.Concurrency = n
+	}
+}
+
 // GetFields sets the fields to retrieve for matching records
 func GetFields(fields ...string) QueryOption {
 	return func(q *Query) {
@@ -76,6 +236,37 @@ func GetFields(fields ...string) QueryOption {
 	}
 }
 
+// AllFields requests every field of the matching records in place of
+// Marketo's default fields; the field names are read from the describe
+// result, which is cached if the Client is configured with a
+// DescribeCacheTTL. The names are sent in the
+// request body, unless PreferGET is set and the URL is short enough, so the
+// number of fields is not limited by the URL length.
+func AllFields() QueryOption {
+	return func(q *Query) {
+		q.AllFields = true
+	}
+}
+
+// RepeatFilterValues sends each filter value as a separate filterValues
+// parameter in place of a comma separated list; this is done automatically
+// when a value contains a comma.
+func RepeatFilterValues() QueryOption {
+	return func(q *Query) {
+		q.RepeatFilterValues = true
+	}
+}
+
+// PreferGET sends the query in the URL of a GET request, which Marketo
+// handles with less overhead than the default POST, unless the URL would be
+// longer than MaximumGETURLLength, in which case it is sent in the body of a
+// POST to avoid URL length errors.
+func PreferGET() QueryOption {
+	return func(q *Query) {
+		q.PreferGET = true
+	}
+}
+
 // GetPage sets the paging token for the query
 func GetPage(t string) QueryOption {
 	return func(q *Query) {
@@ -0,0 +1,50 @@
+package marketo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryValues_defaultBatchSize(t *testing.T) {
+	q := &Query{FilterField: "email", FilterValues: []string{"a@example.com"}}
+
+	for i := 0; i < 2; i++ {
+		values, err := q.Values()
+		require.NoError(t, err)
+		assert.Equal(t, "300", values.Get("batchSize"))
+	}
+	assert.Equal(t, 0, q.BatchSize)
+}
+
+func TestFilterByEmailHash(t *testing.T) {
+	q := &Query{}
+	FilterByEmailHash("emailHash_c", " Tester@Example.com", "other@example.com")(q)
+
+	assert.Equal(t, "emailHash_c", q.FilterField)
+	assert.Equal(t, []string{
+		"f40aca99b2ca1491dbf6ec55597c4397",
+		EmailHash("other@example.com"),
+	}, q.FilterValues)
+	assert.NotContains(t, strings.Join(q.FilterValues, ","), "example.com")
+}
+
+func TestQueryValues_repeatFilterValues(t *testing.T) {
+	q := &Query{FilterField: "company", FilterValues: []string{"Acme", "Globex"}}
+	values, err := q.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme,Globex"}, values["filterValues"])
+
+	RepeatFilterValues()(q)
+	values, err = q.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme", "Globex"}, values["filterValues"])
+
+	// values containing a comma are always repeated
+	q = &Query{FilterField: "company", FilterValues: []string{"Acme, Inc.", "Globex"}}
+	values, err = q.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme, Inc.", "Globex"}, values["filterValues"])
+}
@@ -0,0 +1,54 @@
+// Package marketotest provides helpers for testing code which pages through
+// Marketo REST API responses.
+package marketotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// envelope is the response envelope returned by the Marketo REST API
+type envelope struct {
+	RequestID     string                   `json:"requestId"`
+	Success       bool                     `json:"success"`
+	NextPageToken string                   `json:"nextPageToken,omitempty"`
+	MoreResult    bool                     `json:"moreResult,omitempty"`
+	Result        []map[string]interface{} `json:"result"`
+}
+
+// PageToken returns the nextPageToken included with the response preceding
+// the page at index; requests for the page at index should send it.
+func PageToken(index int) string {
+	return fmt.Sprintf("PAGE%dTOKEN", index)
+}
+
+// MultiPageResponse returns the JSON response bodies serving pages, in order.
+// Each response other than the last includes the PageToken of the following
+// page and sets moreResult, so that a client pages through all of them. If a
+// page cannot be encoded, ie because it contains a NaN, the test fails.
+func MultiPageResponse(tb testing.TB, pages [][]map[string]interface{}) []string {
+	tb.Helper()
+	bodies := make([]string, len(pages))
+	for i, page := range pages {
+		e := envelope{
+			RequestID: fmt.Sprintf("page#%d", i),
+			Success:   true,
+			Result:    page,
+		}
+		if e.Result == nil {
+			e.Result = []map[string]interface{}{}
+		}
+		if i < len(pages)-1 {
+			e.NextPageToken = PageToken(i + 1)
+			e.MoreResult = true
+		}
+
+		body, err := json.Marshal(e)
+		if err != nil {
+			tb.Fatalf("marketotest: unable to encode page %d: %v", i, err)
+		}
+		bodies[i] = string(body)
+	}
+	return bodies
+}
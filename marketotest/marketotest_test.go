@@ -0,0 +1,42 @@
+package marketotest
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiPageResponse(t *testing.T) {
+	bodies := MultiPageResponse(t, [][]map[string]interface{}{
+		{{"id": 1}, {"id": 2}},
+		{{"id": 3}},
+		nil,
+	})
+
+	assert.Equal(t, []string{
+		`{"requestId":"page#0","success":true,"nextPageToken":"PAGE1TOKEN","moreResult":true,"result":[{"id":1},{"id":2}]}`,
+		`{"requestId":"page#1","success":true,"nextPageToken":"PAGE2TOKEN","moreResult":true,"result":[{"id":3}]}`,
+		`{"requestId":"page#2","success":true,"result":[]}`,
+	}, bodies)
+}
+
+// fatalRecorder records the failure reported by Fatalf rather than ending
+// the test
+type fatalRecorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...interface{}) {
+	r.failure = fmt.Sprintf(format, args...)
+}
+
+func TestMultiPageResponse_unencodable(t *testing.T) {
+	r := &fatalRecorder{TB: t}
+	MultiPageResponse(r, [][]map[string]interface{}{{{"score": math.NaN()}}})
+	assert.Equal(t, "marketotest: unable to encode page 0: json: unsupported value: NaN", r.failure)
+}
@@ -0,0 +1,140 @@
+package marketo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	getUsageStats = "get usage stats"
+	getErrorStats = "get error stats"
+)
+
+// APICallCount is the number of calls made to a single API
+type APICallCount struct {
+	APIName string `json:"apiName"`
+	Count   int    `json:"count"`
+}
+
+// UserUsage is the number of API calls made by a single API user
+type UserUsage struct {
+	UserID   string         `json:"userId"`
+	Count    int            `json:"count"`
+	APICalls []APICallCount `json:"apiCalls"`
+}
+
+// DailyUsage is the number of API calls made on Date
+type DailyUsage struct {
+	Date  string      `json:"date"`
+	Total int         `json:"total"`
+	Users []UserUsage `json:"users"`
+}
+
+// UsageStats contains the API calls made over one or more days
+type UsageStats struct {
+	// Total is the number of calls made over all Days
+	Total int
+	Days  []DailyUsage
+}
+
+// ErrorCount is the number of times an error code was returned
+type ErrorCount struct {
+	ErrorCode string `json:"errorCode"`
+	Count     int    `json:"count"`
+}
+
+// DailyErrors is the number of API errors returned on Date
+type DailyErrors struct {
+	Date   string       `json:"date"`
+	Total  int          `json:"total"`
+	Errors []ErrorCount `json:"errors"`
+}
+
+// ErrorStats contains the API errors returned over one or more days
+type ErrorStats struct {
+	// Total is the number of errors returned over all Days
+	Total int
+	Days  []DailyErrors
+}
+
+// StatsAPI provides access to the Marketo API usage statistics
+type StatsAPI struct {
+	*Client
+}
+
+// NewStatsAPI returns a new instance of the stats API, configured with the
+// provided Client.
+func NewStatsAPI(c *Client) *StatsAPI {
+	return &StatsAPI{c}
+}
+
+// Usage returns the API calls made so far today, the quota for which is
+// shared by all API users in the Marketo instance.
+func (s *StatsAPI) Usage(ctx context.Context) (UsageStats, error) {
+	return s.usage(ctx, "usage.json")
+}
+
+// UsageLastWeek returns the API calls made over the last 7 days
+func (s *StatsAPI) UsageLastWeek(ctx context.Context) (UsageStats, error) {
+	return s.usage(ctx, "usage", "last7days.json")
+}
+
+func (s *StatsAPI) usage(ctx context.Context, paths ...string) (UsageStats, error) {
+	stats := UsageStats{}
+	err := s.get(ctx, getUsageStats, &stats.Days, paths...)
+	for _, day := range stats.Days {
+		stats.Total += day.Total
+	}
+	return stats, err
+}
+
+// Errors returns the API errors returned so far today
+func (s *StatsAPI) Errors(ctx context.Context) (ErrorStats, error) {
+	return s.errors(ctx, "errors.json")
+}
+
+// ErrorsLastWeek returns the API errors returned over the last 7 days
+func (s *StatsAPI) ErrorsLastWeek(ctx context.Context) (ErrorStats, error) {
+	return s.errors(ctx, "errors", "last7days.json")
+}
+
+func (s *StatsAPI) errors(ctx context.Context, paths ...string) (ErrorStats, error) {
+	stats := ErrorStats{}
+	err := s.get(ctx, getErrorStats, &stats.Days, paths...)
+	for _, day := range stats.Days {
+		stats.Total += day.Total
+	}
+	return stats, err
+}
+
+// get decodes the result of the stats endpoint at paths into result
+func (s *StatsAPI) get(ctx context.Context, operation string, result interface{}, paths ...string) error {
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, s.restURL(append([]string{"stats"}, paths...)...), nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.doRequest(operation, request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return handleError(operation, resp)
+	}
+
+	response := &Response{}
+	reader := json.NewDecoder(resp.Body)
+	err = reader.Decode(response)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return responseError(operation, resp.StatusCode, response)
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
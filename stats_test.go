@@ -0,0 +1,89 @@
+package marketo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestStatsUsage(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/stats/usage/last7days.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[
+			{"date":"2021-02-01","total":12,"users":[{"userId":"api@polytomic.com","count":12,"apiCalls":[{"apiName":"Get Lead","count":12}]}]},
+			{"date":"2021-02-02","total":30,"users":[]}
+		]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	usage, err := NewStatsAPI(client).UsageLastWeek(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 42, usage.Total)
+	require.Len(t, usage.Days, 2)
+	assert.Equal(t, "2021-02-01", usage.Days[0].Date)
+	assert.Equal(t, 12, usage.Days[0].Total)
+	assert.Equal(t, []UserUsage{{
+		UserID:   "api@polytomic.com",
+		Count:    12,
+		APICalls: []APICallCount{{APIName: "Get Lead", Count: 12}},
+	}}, usage.Days[0].Users)
+	assert.True(t, gock.IsDone())
+}
+
+func TestStatsErrors(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/stats/errors.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1","success":true,"result":[{"date":"2021-02-02","total":3,"errors":[{"errorCode":"1004","count":2},{"errorCode":"610","count":1}]}]}`)
+	gock.New(testHost).
+		Get("/rest/v1/stats/errors.json").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"2","success":false,"errors":[{"code":"603","message":"Access denied"}]}`)
+
+	client, err := NewClient(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+	api := NewStatsAPI(client)
+
+	stats, err := api.Errors(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, []ErrorCount{
+		{ErrorCode: "1004", Count: 2},
+		{ErrorCode: "610", Count: 1},
+	}, stats.Days[0].Errors)
+
+	_, err = api.Errors(context.Background())
+	require.Error(t, err)
+	var mErr Error
+	require.True(t, errors.As(err, &mErr))
+	assert.Equal(t, getErrorStats, mErr.Operation)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,204 @@
+package marketo
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	// MaximumSyncBatchSize is the largest number of records which may be
+	// synced in a single Marketo request.
+	MaximumSyncBatchSize = 300
+)
+
+// SyncAction specifies how Marketo handles records which do or do not
+// already exist when syncing.
+type SyncAction string
+
+const (
+	ActionCreateOnly      SyncAction = "createOnly"
+	ActionUpdateOnly      SyncAction = "updateOnly"
+	ActionCreateOrUpdate  SyncAction = "createOrUpdate"
+	ActionCreateDuplicate SyncAction = "createDuplicate"
+)
+
+const (
+	// DedupeByDedupeFields matches custom object records using the
+	// object's dedupe fields
+	DedupeByDedupeFields = "dedupeFields"
+	// DedupeByIDField matches custom object records using the object's
+	// idField
+	DedupeByIDField = "idField"
+)
+
+// SyncRequest contains the payload sent to Marketo when syncing records
+type SyncRequest struct {
+	Action        SyncAction               `json:"action,omitempty"`
+	LookupField   string                   `json:"lookupField,omitempty"`
+	DedupeBy      string                   `json:"dedupeBy,omitempty"`
+	PartitionName string                   `json:"partitionName,omitempty"`
+	Input         []map[string]interface{} `json:"input"`
+
+	// DryRun is set when the request should be validated but not sent
+	DryRun bool `json:"-"`
+	dryRun *SyncRequest
+	// Timeout limits the duration of the sync, if set
+	Timeout time.Duration `json:"-"`
+
+	// ProgramStatus, Source and Reason are only sent by LeadAPI.Push
+	ProgramStatus string `json:"-"`
+	Source        string `json:"-"`
+	Reason        string `json:"-"`
+}
+
+// SyncOption defines the signature of functional options for Marketo Sync
+// APIs.
+type SyncOption func(*SyncRequest)
+
+// Action sets the sync action; if not set Marketo defaults to
+// createOrUpdate.
+func Action(a SyncAction) SyncOption {
+	return func(r *SyncRequest) {
+		r.Action = a
+	}
+}
+
+// LookupField sets the field used to deduplicate records; if not set Marketo
+// defaults to email for Leads.
+func LookupField(name string) SyncOption {
+	return func(r *SyncRequest) {
+		r.LookupField = name
+	}
+}
+
+// DedupeBy sets how custom object records are matched to existing records;
+// if not set Marketo defaults to DedupeByDedupeFields. Only supported when
+// syncing custom objects.
+func DedupeBy(by string) SyncOption {
+	return func(r *SyncRequest) {
+		r.DedupeBy = by
+	}
+}
+
+// PartitionName sets the lead partition new Leads are created in; if not set
+// Marketo creates them in the default partition. Only supported when syncing
+// Leads.
+func PartitionName(name string) SyncOption {
+	return func(r *SyncRequest) {
+		r.PartitionName = name
+	}
+}
+
+// SyncTimeout limits the duration of the sync without constructing a
+// deadlined context. If the context passed to the sync has an earlier
+// deadline, that is used instead.
+func SyncTimeout(d time.Duration) SyncOption {
+	return func(r *SyncRequest) {
+		r.Timeout = d
+	}
+}
+
+// PushProgramStatus sets the status in the program of the Leads pushed with
+// LeadAPI.Push, ie "Registered"
+func PushProgramStatus(status string) SyncOption {
+	return func(r *SyncRequest) {
+		r.ProgramStatus = status
+	}
+}
+
+// PushSource sets the source recorded for the Leads pushed with
+// LeadAPI.Push, ie "Web Form"
+func PushSource(source string) SyncOption {
+	return func(r *SyncRequest) {
+		r.Source = source
+	}
+}
+
+// PushReason sets the reason recorded for the Leads pushed with LeadAPI.Push
+func PushReason(reason string) SyncOption {
+	return func(r *SyncRequest) {
+		r.Reason = reason
+	}
+}
+
+// DryRun validates the sync request without sending it to Marketo: field
+// names are checked against the describe result, along with the batch size
+// and the presence of the lookup field in each record. If into is not nil, the
+// request which would have been sent is copied into it.
+func DryRun(into *SyncRequest) SyncOption {
+	return func(r *SyncRequest) {
+		r.DryRun = true
+		r.dryRun = into
+	}
+}
+
+// RecordReader supplies the records to sync one at a time, so that they need
+// not all be held in memory. Next returns io.EOF once there are no more
+// records.
+type RecordReader interface {
+	Next() (map[string]interface{}, error)
+}
+
+// RecordReaderFunc adapts a function to a RecordReader
+type RecordReaderFunc func() (map[string]interface{}, error)
+
+// Next returns the result of calling f
+func (f RecordReaderFunc) Next() (map[string]interface{}, error) {
+	return f()
+}
+
+// SliceRecordReader returns a RecordReader which reads records in order
+func SliceRecordReader(records []map[string]interface{}) RecordReader {
+	return RecordReaderFunc(func() (map[string]interface{}, error) {
+		if len(records) == 0 {
+			return nil, io.EOF
+		}
+		record := records[0]
+		records = records[1:]
+		return record, nil
+	})
+}
+
+// writeSyncBatch encodes sr to w, with an input of first followed by the
+// records read from records, up to MaximumSyncBatchSize in all. Records are
+// encoded as they are read, so only one is held at a time. It returns true if
+// records returned io.EOF.
+func writeSyncBatch(w io.Writer, sr SyncRequest, first map[string]interface{}, records RecordReader) (bool, error) {
+	// encode the request with an empty input, and then write the records
+	// in place of its closing "]}"
+	sr.Input = []map[string]interface{}{}
+	header, err := json.Marshal(sr)
+	if err != nil {
+		return false, err
+	}
+	if _, err := w.Write(bytes.TrimSuffix(header, []byte("]}"))); err != nil {
+		return false, err
+	}
+
+	enc := json.NewEncoder(w)
+	record, exhausted := first, false
+	for n := 0; ; n++ {
+		if n > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return false, err
+			}
+		}
+		if err := enc.Encode(record); err != nil {
+			return false, err
+		}
+		if n+1 == MaximumSyncBatchSize {
+			break
+		}
+		if record, err = records.Next(); err == io.EOF {
+			exhausted = true
+			break
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return exhausted, err
+}
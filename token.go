@@ -0,0 +1,189 @@
+package marketo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const authenticate = "authenticate"
+
+// staticTokenTTL is how long a token read from the environment or a file is
+// used before it is read again, so that rotated tokens are picked up.
+var staticTokenTTL = time.Minute
+
+// TokenSource supplies the access token used to authenticate REST API
+// requests, along with the time it expires. The Client requests a new token
+// once the current one expires or Marketo rejects it.
+type TokenSource interface {
+	Token(ctx context.Context) (AuthToken, time.Time, error)
+}
+
+// clientCredentials is the TokenSource which requests tokens from the
+// Marketo identity endpoint using the client_credentials grant.
+type clientCredentials struct {
+	client           *http.Client
+	identityEndpoint string
+	maxResponseBytes int64
+}
+
+// NewClientCredentialsTokenSource returns the TokenSource used by a Client
+// configured without one: tokens are requested from the identity endpoint
+// using the configured client ID and secret.
+func NewClientCredentialsTokenSource(config ClientConfig) (TokenSource, error) {
+	endpoint, err := configEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+	return newClientCredentials(config, endpoint), nil
+}
+
+func newClientCredentials(config ClientConfig, endpoint string) *clientCredentials {
+	return &clientCredentials{
+		client: &http.Client{
+			Timeout: config.timeout(),
+			Transport: &authRoundTripper{
+				clientID:     config.ID,
+				clientSecret: config.Secret,
+				scope:        config.Scope,
+				delegate:     config.transport(config.AuthTransport),
+				userAgent:    config.userAgent(),
+			},
+		},
+		identityEndpoint: endpoint + identityBase + identityPath,
+		maxResponseBytes: config.maxResponseBytes(),
+	}
+}
+
+// Token requests a new access token from the identity endpoint
+func (s *clientCredentials) Token(ctx context.Context) (auth AuthToken, expiresAt time.Time, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.identityEndpoint, nil)
+	if err != nil {
+		return auth, expiresAt, err
+	}
+	resp, err := withDeadline(s.client, req).Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return auth, expiresAt, ctx.Err()
+		}
+		return auth, expiresAt, errors.New("Unable to get Market auth token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, err := readBody(resp.Body, s.maxResponseBytes)
+		if err != nil {
+			return auth, expiresAt, Error{
+				Operation:  authenticate,
+				Message:    "Server error getting marketo auth token",
+				StatusCode: resp.StatusCode,
+			}
+		}
+		response := Response{}
+		if json.Unmarshal(body, &response) == nil && len(response.Errors) > 0 {
+			return auth, expiresAt, responseError(authenticate, resp.StatusCode, &response)
+		}
+		return auth, expiresAt, authError(resp.StatusCode, body)
+	}
+
+	// Marketo reports some failures, such as an endpoint which does not
+	// match the credentials' subscription, with the REST API's error
+	// Reasons and a 200 status
+	var result struct {
+		AuthToken
+		Response
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return auth, expiresAt, Error{
+			Operation:  authenticate,
+			Message:    "Unable to decode marketo error token",
+			StatusCode: resp.StatusCode,
+		}
+	}
+	if result.AccessToken == "" && len(result.Errors) > 0 {
+		return auth, expiresAt, responseError(authenticate, resp.StatusCode, &result.Response)
+	}
+	auth = result.AuthToken
+	return auth, time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second), nil
+}
+
+// CloseIdleConnections closes any idle connections to the identity endpoint
+func (s *clientCredentials) CloseIdleConnections() {
+	s.client.CloseIdleConnections()
+}
+
+// envTokenSource reads the access token from an environment variable
+type envTokenSource struct {
+	name string
+}
+
+// EnvTokenSource returns a TokenSource which reads the access token from the
+// named environment variable. The variable contains either the token itself
+// or the JSON returned by the identity endpoint; it is read again each minute,
+// or sooner if the token's expires_in passes.
+func EnvTokenSource(name string) TokenSource {
+	return envTokenSource{name: name}
+}
+
+func (s envTokenSource) Token(ctx context.Context) (AuthToken, time.Time, error) {
+	value, ok := os.LookupEnv(s.name)
+	if !ok {
+		return AuthToken{}, time.Time{}, fmt.Errorf("environment variable %s is not set", s.name)
+	}
+	return parseToken(value, time.Now())
+}
+
+// fileTokenSource reads the access token from a file
+type fileTokenSource struct {
+	path string
+}
+
+// FileTokenSource returns a TokenSource which reads the access token from the
+// file at path, as written by a process managing the credentials. The file
+// contains either the token itself or the JSON returned by the identity
+// endpoint, in which case expires_in counts from the file's modification time.
+// The file is read again each minute, or sooner if the token expires.
+func FileTokenSource(path string) TokenSource {
+	return fileTokenSource{path: path}
+}
+
+func (s fileTokenSource) Token(ctx context.Context) (AuthToken, time.Time, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return AuthToken{}, time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return AuthToken{}, time.Time{}, err
+	}
+	return parseToken(string(data), info.ModTime())
+}
+
+// parseToken parses an access token, or the JSON returned by the identity
+// endpoint, issued at the provided time.
+func parseToken(value string, issued time.Time) (auth AuthToken, expiresAt time.Time, err error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		if err := json.Unmarshal([]byte(value), &auth); err != nil {
+			return auth, expiresAt, fmt.Errorf("invalid access token: %w", err)
+		}
+	} else {
+		auth.AccessToken = value
+	}
+	if auth.AccessToken == "" {
+		return auth, expiresAt, errors.New("empty access token")
+	}
+
+	expiresAt = time.Now().Add(staticTokenTTL)
+	if auth.ExpiresIn > 0 {
+		if expires := issued.Add(time.Duration(auth.ExpiresIn) * time.Second); expires.Before(expiresAt) {
+			expiresAt = expires
+		}
+	}
+	return auth, expiresAt, nil
+}
@@ -0,0 +1,170 @@
+package marketo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestEnvTokenSource(t *testing.T) {
+	defer gock.Off()
+	defer os.Unsetenv("MARKETO_TEST_TOKEN")
+	require.NoError(t, os.Setenv("MARKETO_TEST_TOKEN", "aaaa-bbbb-cccc\n"))
+
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		MatchHeader("Authorization", "Bearer aaaa-bbbb-cccc").
+		Reply(http.StatusOK).
+		JSON(`{"requestId":"1000","success":true,"result":[]}`)
+
+	client, err := NewClient(ClientConfig{
+		Endpoint:    testHost,
+		TokenSource: EnvTokenSource("MARKETO_TEST_TOKEN"),
+	})
+	require.NoError(t, err)
+
+	info, ok := client.GetTokenInfo()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(staticTokenTTL), info.Expires, time.Second)
+
+	_, err = client.Get("/rest/v1/leads.json")
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+
+	_, _, err = EnvTokenSource("MARKETO_TEST_TOKEN_UNSET").Token(context.Background())
+	assert.Error(t, err)
+}
+
+func TestFileTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marketo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	require.NoError(t, ioutil.WriteFile(path,
+		[]byte(`{"access_token":"dddd-eeee-ffff","token_type":"bearer","expires_in":30,"scope":"tester@example.com"}`),
+		0600,
+	))
+	modified := time.Now().Add(-10 * time.Second)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	auth, expiresAt, err := FileTokenSource(path).Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "dddd-eeee-ffff", auth.AccessToken)
+	assert.Equal(t, "tester@example.com", auth.Scope)
+	assert.WithinDuration(t, modified.Add(30*time.Second), expiresAt, time.Second)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(" \n"), 0600))
+	_, _, err = FileTokenSource(path).Token(context.Background())
+	assert.Error(t, err)
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		MatchParam("client_id", clientID).
+		MatchParam("grant_type", "client_credentials").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+
+	source, err := NewClientCredentialsTokenSource(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	auth, expiresAt, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, auth.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(time.Duration(auth.ExpiresIn)*time.Second), expiresAt, time.Second)
+	assert.True(t, gock.IsDone())
+}
+
+func TestClientCredentialsTokenSource_undecodable(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		BodyString("not json")
+
+	source, err := NewClientCredentialsTokenSource(ClientConfig{
+		ID:       clientID,
+		Secret:   clientSecret,
+		Endpoint: testHost,
+	})
+	require.NoError(t, err)
+
+	_, _, err = source.Token(context.Background())
+	require.Error(t, err)
+	var merr Error
+	require.True(t, errors.As(err, &merr))
+	assert.Equal(t, authenticate, merr.Operation)
+	assert.Equal(t, "authenticate: Unable to decode marketo error token", err.Error())
+	assert.True(t, gock.IsDone())
+}
+
+func TestClientCredentialsScope(t *testing.T) {
+	for _, scope := range []string{"", "user@example.com"} {
+		t.Run(scope, func(t *testing.T) {
+			defer gock.Off()
+
+			gock.New(testHost).
+				Get("/identity/oauth/token").
+				AddMatcher(func(r *http.Request, tr *gock.Request) (bool, error) {
+					values := r.URL.Query()
+					_, ok := values["scope"]
+					assert.Equal(t, scope != "", ok)
+					assert.Equal(t, scope, values.Get("scope"))
+					return true, nil
+				}).
+				Reply(http.StatusOK).
+				JSON(authResponseSuccess)
+
+			_, err := NewClient(ClientConfig{
+				ID:       clientID,
+				Secret:   clientSecret,
+				Endpoint: testHost,
+				Scope:    scope,
+			})
+			require.NoError(t, err)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func TestNewClient_invalidSubscription(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			defer gock.Off()
+
+			gock.New(testHost).
+				Get("/identity/oauth/token").
+				Reply(status).
+				JSON(`{"requestId":"e42b#14272d07d78","success":false,"errors":[{"code":"614","message":"Invalid subscription"}]}`)
+
+			_, err := NewClient(ClientConfig{
+				ID:       clientID,
+				Secret:   clientSecret,
+				Endpoint: testHost,
+			})
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidSubscription), err)
+			assert.Equal(t, "authenticate: [614] Invalid subscription (status "+strconv.Itoa(status)+", requestId e42b#14272d07d78)", err.Error())
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package marketo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// tracerName is the instrumentation name passed to the TracerProvider
+	tracerName = "github.com/polytomic/go-marketo"
+)
+
+// TracerProvider supplies the Tracer used to instrument Marketo requests. It
+// mirrors the shape of OpenTelemetry's trace.TracerProvider so that a thin
+// adapter is all that's required, without this package depending on otel.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts a new Span as a child of any span contained in ctx.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced Marketo operation.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// noopSpan is used when no TracerProvider has been configured
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) RecordError(error)                {}
+func (noopSpan) End()                             {}
+
+// spanName converts an operation name ("filter leads") to the span name
+// reported to the tracer ("marketo.filterLeads").
+func spanName(operation string) string {
+	words := strings.Fields(operation)
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return "marketo." + strings.Join(words, "")
+}
+
+// startSpan starts a span for the operation, returning the request with the
+// span's context attached.
+func (c *Client) startSpan(operation string, req *http.Request) (*http.Request, Span) {
+	if c.tracer == nil {
+		return req, noopSpan{}
+	}
+
+	ctx, span := c.tracer.Start(req.Context(), spanName(operation))
+	span.SetAttribute("url.path", req.URL.Path)
+	return req.WithContext(ctx), span
+}
+
+// recordReasons attaches the Marketo error codes to span.
+func recordReasons(span Span, reasons []Reason) {
+	if len(reasons) == 0 {
+		return
+	}
+
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = r.Code
+		span.RecordError(r)
+	}
+	span.SetAttribute("marketo.error_code", strings.Join(codes, ","))
+}
@@ -0,0 +1,130 @@
+package marketo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+type testSpan struct {
+	name       string
+	attributes map[string]interface{}
+	errors     []error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.errors = append(s.errors, err) }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Tracer(string) Tracer { return t }
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestSpanName(t *testing.T) {
+	assert.Equal(t, "marketo.filterLeads", spanName(filterLeads))
+	assert.Equal(t, "marketo.get", spanName("get"))
+}
+
+func TestTracing(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads/describe2.json").
+		Reply(http.StatusOK).
+		File("test-fixtures/leads-describe2.json")
+	gock.New(testHost).
+		Post("/rest/v1/leads.json").
+		Reply(http.StatusNotFound).
+		JSON(`{"success":false,"errors":[{"code":"610","message":"Requested resource not found"}]}`)
+
+	tracer := &testTracer{}
+	client, err := NewClient(ClientConfig{
+		ID:             clientID,
+		Secret:         clientSecret,
+		Endpoint:       "https://marketo.testing",
+		TracerProvider: tracer,
+	})
+	require.NoError(t, err)
+
+	api := NewLeadAPI(client)
+	_, err = api.DescribeFields(context.Background())
+	require.NoError(t, err)
+	_, _, err = api.Filter(
+		context.Background(),
+		FilterField("email"),
+		FilterValues([]string{"nathan@polytomic.com"}),
+	)
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 2)
+	describe := tracer.spans[0]
+	assert.Equal(t, "marketo.describe2Lead", describe.name)
+	assert.Equal(t, "/rest/v1/leads/describe2.json", describe.attributes["url.path"])
+	assert.Equal(t, http.StatusOK, describe.attributes["http.status_code"])
+	assert.True(t, describe.ended)
+
+	filter := tracer.spans[1]
+	assert.Equal(t, "marketo.filterLeads", filter.name)
+	assert.Equal(t, http.StatusNotFound, filter.attributes["http.status_code"])
+	assert.Equal(t, "610", filter.attributes["marketo.error_code"])
+	assert.Len(t, filter.errors, 1)
+	assert.True(t, filter.ended)
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, gock.IsDone())
+}
+
+func TestTracing_doErrorResponse(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(testHost).
+		Get("/identity/oauth/token").
+		Reply(http.StatusOK).
+		JSON(authResponseSuccess)
+	gock.New(testHost).
+		Get("/rest/v1/leads.json").
+		Reply(http.StatusNotFound).
+		JSON(`{"success":false,"errors":[{"code":"610","message":"Requested resource not found"}]}`)
+
+	tracer := &testTracer{}
+	client, err := NewClient(ClientConfig{
+		ID:             clientID,
+		Secret:         clientSecret,
+		Endpoint:       "https://marketo.testing",
+		TracerProvider: tracer,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Get("/rest/v1/leads.json")
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, "marketo.get", span.name)
+	assert.Equal(t, http.StatusNotFound, span.attributes["http.status_code"])
+	assert.Equal(t, "610", span.attributes["marketo.error_code"])
+	assert.True(t, span.ended)
+
+	var merr Error
+	require.True(t, errors.As(err, &merr))
+	assert.Equal(t, "get", merr.Operation)
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,119 @@
+package marketo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncationWarning describes a field value which Marketo truncated because
+// it exceeded the field's Length.
+type TruncationWarning struct {
+	// RecordID identifies the record: its ID, or marketoGUID for custom
+	// objects, if known
+	RecordID string
+	// Index is the position of the record in the sync input, or of the
+	// warning in those returned by ImportAPI.Warnings, counting from zero
+	Index int
+	Field string
+	// OriginalLength is the length, in characters, of the value sent to
+	// Marketo; it is 0 if the value is not known
+	OriginalLength int
+	// MaxLength is the field's Length, if Marketo included it in the
+	// warning
+	MaxLength int
+	// Message is the warning returned by Marketo
+	Message string
+}
+
+var (
+	truncationQuotedField = regexp.MustCompile(`['"]([^'"]+)['"]`)
+	truncationField       = regexp.MustCompile(`(?i)field\s+([A-Za-z0-9_]+)`)
+	truncationMaxLength   = regexp.MustCompile(`(?i)max(?:imum)?(?:\s+length)?(?:\s+(?:of|is))?[\s:=]*(\d+)`)
+)
+
+// parseTruncation returns the TruncationWarning described by message, if it
+// reports a truncated or over length field value.
+func parseTruncation(message string) (TruncationWarning, bool) {
+	lower := strings.ToLower(message)
+	if !strings.Contains(lower, "truncat") &&
+		!(strings.Contains(lower, "exceed") && strings.Contains(lower, "length")) {
+		return TruncationWarning{}, false
+	}
+
+	w := TruncationWarning{Message: message}
+	if m := truncationQuotedField.FindStringSubmatch(message); m != nil {
+		w.Field = m[1]
+	} else if m := truncationField.FindStringSubmatch(message); m != nil {
+		w.Field = m[1]
+	}
+	if m := truncationMaxLength.FindStringSubmatch(message); m != nil {
+		w.MaxLength, _ = strconv.Atoi(m[1])
+	}
+	return w, true
+}
+
+// valueLength returns the length in characters of a field value, or 0 if it
+// is not set
+func valueLength(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return utf8.RuneCountInString(v)
+	default:
+		return utf8.RuneCountInString(fmt.Sprint(v))
+	}
+}
+
+// SyncTruncations returns the truncated field values reported in the Reasons
+// of the results of a sync. The results are in the order of input, which is
+// used to find the length of each truncated value; input may be nil if it is
+// no longer available.
+func SyncTruncations(input []map[string]interface{}, results []RecordResult) []TruncationWarning {
+	warnings := []TruncationWarning{}
+	for i, r := range results {
+		for _, reason := range r.Reasons {
+			w, ok := parseTruncation(reason.Message)
+			if !ok {
+				continue
+			}
+			w.Index = i
+			w.RecordID = r.MarketoGUID
+			if r.ID != 0 {
+				w.RecordID = strconv.Itoa(r.ID)
+			}
+			if i < len(input) && w.Field != "" {
+				w.OriginalLength = valueLength(input[i][w.Field])
+			}
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+// ImportTruncations returns the truncated field values reported in the
+// warnings of an import, as returned by ImportAPI.Warnings.
+func ImportTruncations(warnings []LeadImportWarning) []TruncationWarning {
+	truncations := []TruncationWarning{}
+	for i, iw := range warnings {
+		w, ok := parseTruncation(iw.Warning)
+		if !ok {
+			continue
+		}
+		w.Index = i
+		for _, f := range []string{FilterTypeID, FilterTypeGUID} {
+			if id, ok := iw.Fields[f].(string); ok && id != "" {
+				w.RecordID = id
+				break
+			}
+		}
+		if w.Field != "" {
+			w.OriginalLength = valueLength(iw.Fields[w.Field])
+		}
+		truncations = append(truncations, w)
+	}
+	return truncations
+}
@@ -0,0 +1,49 @@
+package marketo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTruncation(t *testing.T) {
+	for message, expected := range map[string]TruncationWarning{
+		"Value for field 'title' truncated to maximum length 20": {Field: "title", MaxLength: 20},
+		`Field "company" exceeds max length of 255, truncated`:   {Field: "company", MaxLength: 255},
+		"Value truncated for field notes_c":                      {Field: "notes_c"},
+	} {
+		w, ok := parseTruncation(message)
+		assert.True(t, ok, message)
+		expected.Message = message
+		assert.Equal(t, expected, w)
+	}
+
+	_, ok := parseTruncation("Lead not found")
+	assert.False(t, ok)
+}
+
+func TestSyncTruncations(t *testing.T) {
+	input := []map[string]interface{}{
+		{"email": "tester@example.com"},
+		{"email": "other@example.com", "title": "Vice President of Everything"},
+	}
+	results := []RecordResult{
+		{ID: 50, Status: RecordUpdated},
+		{ID: 51, Status: RecordUpdated, Reasons: []Reason{
+			{Code: "1077", Message: "Value for field 'title' truncated to maximum length 20"},
+		}},
+	}
+
+	expected := []TruncationWarning{{
+		RecordID:       "51",
+		Index:          1,
+		Field:          "title",
+		OriginalLength: 28,
+		MaxLength:      20,
+		Message:        "Value for field 'title' truncated to maximum length 20",
+	}}
+	assert.Equal(t, expected, SyncTruncations(input, results))
+
+	expected[0].OriginalLength = 0
+	assert.Equal(t, expected, SyncTruncations(nil, results))
+}